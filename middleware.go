@@ -7,8 +7,10 @@ import "context"
 //
 // For some examples of middlewares, see github.com/bennydictor/taskset/middlewares.
 type Middleware struct {
-	// Run injects code into task execution. Middlewares must call next() exactly once
-	// during Run.  Middlewares may examine and modify task's properties at any point
+	// Run injects code into task execution. Middlewares must call next() at most once
+	// during Run.  Middlewares that don't call next() prevent the task from running and
+	// must return its result themselves, the middlewares after them won't see the task run.
+	// Middlewares may examine and modify task's properties at any point
 	// during Run.  Middlewares may pass a modified context to next(), although it must
 	// be derived from the input context.  Middlewares may examine and modify the
 	// task's result before returning it.  Leave Run equal to nil to not do anything on
@@ -24,6 +26,15 @@ type Middleware struct {
 	// caller of depend(), it doesn't actually modify the dependent task's result.
 	// Leave Depend equal to nil to not do anything on dependency declaration.
 	Depend func(ctx context.Context, task, dependency *Task, next func(ctx context.Context) Result) Result
+
+	// Start injects code into task set execution.  TaskSet.Start calls Start in a separate
+	// goroutine.  Middlewares must call next() exactly once during Start.  next() starts all
+	// non-lazy tasks, and returns once they have all finished.  TaskSet.Wait unblocks once
+	// Start returns.  Middlewares may pass a modified context to next(), although it must be
+	// derived from the input context; it's the context that's passed to the non-lazy tasks.
+	// Start is not called for child task sets created by NewSub.  Leave Start equal to nil
+	// to not do anything on task set execution.
+	Start func(ctx context.Context, taskSet *TaskSet, next func(ctx context.Context))
 }
 
 func composeRun(mw1, mw2 Middleware) func(ctx context.Context, task *Task, next func(ctx context.Context) Result) Result {
@@ -56,10 +67,26 @@ func composeDepend(mw1, mw2 Middleware) func(ctx context.Context, task, dependen
 	}
 }
 
+func composeStart(mw1, mw2 Middleware) func(ctx context.Context, taskSet *TaskSet, next func(ctx context.Context)) {
+	if mw1.Start == nil {
+		return mw2.Start
+	}
+	if mw2.Start == nil {
+		return mw1.Start
+	}
+
+	return func(ctx context.Context, taskSet *TaskSet, next func(ctx context.Context)) {
+		mw1.Start(ctx, taskSet, func(ctx context.Context) {
+			mw2.Start(ctx, taskSet, next)
+		})
+	}
+}
+
 func composeMiddlewares(mw1, mw2 Middleware) Middleware {
 	return Middleware{
 		Run:    composeRun(mw1, mw2),
 		Depend: composeDepend(mw1, mw2),
+		Start:  composeStart(mw1, mw2),
 	}
 }
 
@@ -71,6 +98,63 @@ func chainMiddlewares(middlewares []Middleware) (result Middleware) {
 }
 
 // middleware is a monoid:
-// mempty = Middleware{id, id}
+// mempty = Middleware{id, id, id}
 // mappend = composeMiddlewares
 // mconcat = chainMiddlewares
+
+// enteredKey is the key of a context value, which holds the number of the task's
+// middlewares whose Run has been entered.
+type enteredKey struct {
+	task *Task
+}
+
+// runMiddlewares is like chainMiddlewares(middlewares).Run, except it lets Task.Depend
+// know which of the middlewares have been entered.
+func runMiddlewares(ctx context.Context, task *Task, middlewares []Middleware, next func(ctx context.Context) Result) Result {
+	return runMiddlewaresFrom(ctx, task, middlewares, 0, next)
+}
+
+func runMiddlewaresFrom(ctx context.Context, task *Task, middlewares []Middleware, i int, next func(ctx context.Context) Result) Result {
+	for ; i < len(middlewares); i++ {
+		if middlewares[i].Run != nil {
+			break
+		}
+	}
+	if i == len(middlewares) {
+		return next(ctx)
+	}
+
+	ctx = context.WithValue(ctx, enteredKey{task}, i+1)
+	return middlewares[i].Run(ctx, task, func(ctx context.Context) Result {
+		return runMiddlewaresFrom(ctx, task, middlewares, i+1, next)
+	})
+}
+
+// dependMiddlewares is the same as chainMiddlewares(middlewares).Depend.
+func dependMiddlewares(ctx context.Context, task, dependency *Task, middlewares []Middleware, next func(ctx context.Context) Result) Result {
+	for i, mw := range middlewares {
+		if mw.Depend != nil {
+			return mw.Depend(ctx, task, dependency, func(ctx context.Context) Result {
+				return dependMiddlewares(ctx, task, dependency, middlewares[i+1:], next)
+			})
+		}
+	}
+	return next(ctx)
+}
+
+type middlewareProperty struct{}
+
+// WithMiddleware adds middlewares to a single task. They are composed inside
+// the task set's middlewares, so the task set's middlewares see them as part of
+// next(). Applying WithMiddleware several times chains the middlewares in order.
+//
+// The Depend part of the given middlewares is called when this task declares
+// a dependency, not when other tasks depend on this one.
+func WithMiddleware(middlewares ...Middleware) Property {
+	return func(task *Task) {
+		task.ModifyProperty(middlewareProperty{}, func(value interface{}) interface{} {
+			mws, _ := value.([]Middleware)
+			return append(mws[:len(mws):len(mws)], middlewares...)
+		})
+	}
+}
@@ -56,3 +56,57 @@ func ExampleMiddleware() {
 	// B depend on A finished
 	// B finished
 }
+
+func ExampleMiddleware_start() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewNamedTaskSet("example", taskset.Middleware{
+		Start: func(ctx context.Context, taskSet *taskset.TaskSet, next func(ctx context.Context)) {
+			fmt.Println(taskSet.Name(), "starting")
+			next(ctx)
+			fmt.Println(taskSet.Name(), "finished")
+		},
+	})
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		fmt.Println("A running")
+		return nil, nil
+	})
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Output:
+	// example starting
+	// A running
+	// example finished
+}
+
+func ExampleWithMiddleware() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet()
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		a := depend(ctx, taskA).Value.(int)
+		return a + 1, nil
+	},
+		properties.WithName("B"),
+		taskset.WithMiddleware(NewPrinter()),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Output:
+	// B starting
+	// B depend on A starting
+	// B depend on A finished
+	// B finished
+}
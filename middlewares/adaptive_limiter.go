@@ -0,0 +1,255 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+)
+
+// AdaptiveLimiterOptions configures an AdaptiveLimiter.
+type AdaptiveLimiterOptions struct {
+	// InitialLimit is the concurrency limit to start with. If InitialLimit is zero, 4 is used.
+	InitialLimit int
+	// MinLimit is the lowest the limit can go. If MinLimit is zero, 1 is used.
+	MinLimit int
+	// MaxLimit is the highest the limit can go. If MaxLimit is zero, 1000 is used.
+	MaxLimit int
+	// Tolerance is how many times a task's self time can exceed the baseline self time,
+	// before the limit is decreased. If Tolerance is zero, 2 is used.
+	Tolerance float64
+	// Backoff is the factor the limit is multiplied by when it's decreased. If Backoff is zero, 0.75 is used.
+	Backoff float64
+	// Overloaded reports whether a task's error signals overload, and so decreases the limit.
+	// Other errors, e.g. context.Canceled, say nothing about the load, and don't change the limit.
+	// If Overloaded is nil, only timeouts signal overload: errors with a Timeout() bool method
+	// that returns true, like context.DeadlineExceeded and net.Error.
+	Overloaded func(err error) bool
+
+	// Key returns the key of a task, e.g. properties.Group. Tasks with different keys
+	// are limited separately, each key having its own limit.
+	// If Key is nil, all tasks share the same limit, with the empty key.
+	Key func(task *taskset.Task) string
+	// OnLimitChange, if not nil, is called each time the integer limit for a key changes.
+	// It's called with the limiter locked, so it must not call the limiter's methods.
+	OnLimitChange func(key string, limit int)
+}
+
+// AdaptiveLimiter provides a middleware that limits concurrency, like NewConcurrencyLimiter
+// with NewSemaphore, but tunes the limit at runtime, based on task self times, which exclude
+// the time the task spends in depend().
+//
+// The limit follows the AIMD (additive increase, multiplicative decrease) algorithm.
+// Each task that finishes with self time under Tolerance times the baseline self time
+// increases the limit by 1/limit, so the limit grows by one every limit tasks.
+// A task that takes longer, or fails with an error that signals overload, see
+// AdaptiveLimiterOptions.Overloaded, signals overload, and multiplies the limit by Backoff,
+// at most once per the task's self time, so that a burst of slow tasks decreases it only once.
+// The baseline is the lowest self time observed, which slowly drifts towards the recent
+// self times, so that it adapts to changes in workload.
+//
+// An AdaptiveLimiter can be used by many task sets to limit them together.
+type AdaptiveLimiter struct {
+	sync.Mutex
+	opts   AdaptiveLimiterOptions
+	limits map[string]*adaptiveLimit
+}
+
+type adaptiveLimit struct {
+	limit        float64
+	inFlight     int
+	baseline     time.Duration
+	lastDecrease time.Time
+	// changed is closed and replaced each time a slot may have become available.
+	changed chan struct{}
+}
+
+// NewAdaptiveLimiter creates a new AdaptiveLimiter.
+func NewAdaptiveLimiter(opts AdaptiveLimiterOptions) *AdaptiveLimiter {
+	if opts.InitialLimit == 0 {
+		opts.InitialLimit = 4
+	}
+	if opts.MinLimit == 0 {
+		opts.MinLimit = 1
+	}
+	if opts.MaxLimit == 0 {
+		opts.MaxLimit = 1000
+	}
+	if opts.Tolerance == 0 {
+		opts.Tolerance = 2
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = 0.75
+	}
+	if opts.Key == nil {
+		opts.Key = func(*taskset.Task) string { return "" }
+	}
+	if opts.Overloaded == nil {
+		opts.Overloaded = isTimeout
+	}
+
+	return &AdaptiveLimiter{
+		opts:   opts,
+		limits: make(map[string]*adaptiveLimit),
+	}
+}
+
+// Limit returns the current concurrency limit for the given key.
+func (a *AdaptiveLimiter) Limit(key string) int {
+	a.Lock()
+	defer a.Unlock()
+
+	return int(a.get(key).limit)
+}
+
+// Limits returns the current concurrency limits for all keys seen so far.
+func (a *AdaptiveLimiter) Limits() map[string]int {
+	a.Lock()
+	defer a.Unlock()
+
+	limits := make(map[string]int, len(a.limits))
+	for key, l := range a.limits {
+		limits[key] = int(l.limit)
+	}
+	return limits
+}
+
+// Middleware provides the taskset.Middleware.
+//
+// Like NewConcurrencyLimiter, it releases the task's slot while the task is in the process
+// of depending on another task, and honours the task's context when waiting for a slot.
+func (a *AdaptiveLimiter) Middleware() taskset.Middleware {
+	return newConcurrencyLimiter(func(task *taskset.Task) (ContextLocker, func(taskset.Result)) {
+		slot := &adaptiveSlot{limiter: a, key: a.opts.Key(task)}
+		return slot, slot.release
+	})
+}
+
+func (a *AdaptiveLimiter) get(key string) *adaptiveLimit {
+	l, ok := a.limits[key]
+	if !ok {
+		l = &adaptiveLimit{
+			limit:   float64(a.opts.InitialLimit),
+			changed: make(chan struct{}),
+		}
+		a.limits[key] = l
+	}
+	return l
+}
+
+func (a *AdaptiveLimiter) broadcast(l *adaptiveLimit) {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (a *AdaptiveLimiter) setLimit(key string, l *adaptiveLimit, limit float64) {
+	limit = math.Max(limit, float64(a.opts.MinLimit))
+	limit = math.Min(limit, float64(a.opts.MaxLimit))
+
+	old := int(l.limit)
+	l.limit = limit
+	if int(limit) != old {
+		a.broadcast(l)
+		if a.opts.OnLimitChange != nil {
+			a.opts.OnLimitChange(key, int(limit))
+		}
+	}
+}
+
+func (a *AdaptiveLimiter) sample(key string, selfTime time.Duration, err error) {
+	a.Lock()
+	defer a.Unlock()
+
+	l := a.get(key)
+
+	var overloaded bool
+	if err != nil {
+		if !a.opts.Overloaded(err) {
+			return
+		}
+		overloaded = true
+	} else {
+		if l.baseline == 0 || selfTime < l.baseline {
+			l.baseline = selfTime
+		} else {
+			l.baseline += (selfTime - l.baseline) / 100
+		}
+		overloaded = float64(selfTime) > a.opts.Tolerance*float64(l.baseline)
+	}
+
+	if !overloaded {
+		a.setLimit(key, l, l.limit+1/l.limit)
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(l.lastDecrease) >= selfTime {
+		l.lastDecrease = now
+		a.setLimit(key, l, l.limit*a.opts.Backoff)
+	}
+}
+
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// adaptiveSlot is a task's slot of an adaptive limit. It measures the time it's locked,
+// which is the task's self time.
+type adaptiveSlot struct {
+	limiter  *AdaptiveLimiter
+	key      string
+	locked   time.Time
+	selfTime time.Duration
+}
+
+func (s *adaptiveSlot) Lock() {
+	_ = s.LockContext(context.Background())
+}
+
+func (s *adaptiveSlot) LockContext(ctx context.Context) error {
+	a := s.limiter
+
+	a.Lock()
+	defer a.Unlock()
+
+	l := a.get(s.key)
+	for l.inFlight >= int(l.limit) {
+		changed := l.changed
+		a.Unlock()
+		select {
+		case <-changed:
+			a.Lock()
+		case <-ctx.Done():
+			a.Lock()
+			return ctx.Err()
+		}
+	}
+
+	l.inFlight++
+	s.locked = time.Now()
+	return nil
+}
+
+func (s *adaptiveSlot) Unlock() {
+	a := s.limiter
+
+	a.Lock()
+	defer a.Unlock()
+
+	s.selfTime += time.Since(s.locked)
+	l := a.get(s.key)
+	l.inFlight--
+	a.broadcast(l)
+}
+
+func (s *adaptiveSlot) release(result taskset.Result) {
+	if s.locked.IsZero() {
+		// The task never got a slot.
+		return
+	}
+	s.limiter.sample(s.key, s.selfTime, result.Err)
+}
@@ -0,0 +1,74 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+)
+
+func ExampleNewAdaptiveLimiter_failure() {
+	ctx := context.Background()
+
+	limiter := middlewares.NewAdaptiveLimiter(middlewares.AdaptiveLimiterOptions{
+		InitialLimit: 8,
+		OnLimitChange: func(key string, limit int) {
+			fmt.Println("limit:", limit)
+		},
+	})
+
+	taskSet := taskset.NewTaskSet(
+		limiter.Middleware(),
+	)
+
+	// A timeout signals overload, so the limit is decreased.
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, fmt.Errorf("call api: %w", context.DeadlineExceeded)
+	})
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Output: limit: 6
+}
+
+func TestAdaptiveLimiter_errors(t *testing.T) {
+	errOverloaded := errors.New("overloaded")
+
+	for _, test := range []struct {
+		name       string
+		err        error
+		overloaded func(error) bool
+		wantLimit  int
+	}{
+		{name: "timeout", err: context.DeadlineExceeded, wantLimit: 6},
+		{name: "cancelled", err: context.Canceled, wantLimit: 8},
+		{name: "other error", err: errOverloaded, wantLimit: 8},
+		{
+			name: "custom predicate",
+			err:  fmt.Errorf("call api: %w", errOverloaded),
+			overloaded: func(err error) bool {
+				return errors.Is(err, errOverloaded)
+			},
+			wantLimit: 6,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := middlewares.NewAdaptiveLimiter(middlewares.AdaptiveLimiterOptions{
+				InitialLimit: 8,
+				Overloaded:   test.overloaded,
+			})
+
+			run(limiter.Middleware(), func(context.Context, taskset.Depend) (interface{}, error) {
+				return nil, test.err
+			})
+
+			if limit := limiter.Limit(""); limit != test.wantLimit {
+				t.Errorf("limit = %v, want %v", limit, test.wantLimit)
+			}
+		})
+	}
+}
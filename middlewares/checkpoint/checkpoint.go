@@ -0,0 +1,117 @@
+// Package checkpoint provides a middleware that saves the results of successful tasks,
+// so that a failed TaskSet can be resumed without running them again.
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+)
+
+// Store persists encoded task results by their keys.
+// Store must be safe for concurrent use.
+type Store interface {
+	// Load returns data saved with the given key.
+	// If there is no such data, Load returns false and a nil error.
+	Load(key string) ([]byte, bool, error)
+	// Save saves data with the given key, replacing the previous data if any.
+	Save(key string, data []byte) error
+}
+
+type keyProperty struct{}
+
+// WithKey sets the key that the task's result is stored with.
+// The key must be the same between runs, and unique within a Store.
+func WithKey(key string) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(keyProperty{}, func(_ interface{}) interface{} {
+			return key
+		})
+	}
+}
+
+type codecProperty struct{}
+
+// WithCodec sets the codec used to store the task's result,
+// overriding the one passed to NewCheckpoint.
+func WithCodec(codec Codec) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(codecProperty{}, func(_ interface{}) interface{} {
+			return codec
+		})
+	}
+}
+
+type disableCheckpointProperty struct{}
+
+// WithDisableCheckpoint disables checkpointing for a particular task.
+var WithDisableCheckpoint taskset.Property = func(task *taskset.Task) {
+	task.ModifyProperty(disableCheckpointProperty{}, func(_ interface{}) interface{} {
+		return struct{}{}
+	})
+}
+
+// Key gets the key that the task's result is stored with. It's the key set by WithKey,
+// or properties.Name(task) if there's none. This function should only be used by middlewares.
+func Key(task *taskset.Task) string {
+	if key, ok := task.Property(keyProperty{}).(string); ok {
+		return key
+	}
+	return properties.Name(task)
+}
+
+// NewCheckpoint creates a middleware that saves the value of every successful task to store,
+// encoded with codec. If store already has a value for a task, the task won't run,
+// and the decoded value becomes its result instead.
+//
+// Results are stored with the key returned by Key. Tasks with an empty key, as well as tasks
+// with WithDisableCheckpoint, are always run and never saved. Failed tasks are never saved,
+// so rerunning a TaskSet only runs the tasks that failed or didn't run, and tasks that depend on them.
+//
+// In a dry run, tasks with a saved value are still not run, but nothing is saved.
+//
+// If store or codec return an error, it becomes the result of the task.
+func NewCheckpoint(store Store, codec Codec) taskset.Middleware {
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			key := Key(task)
+			if key == "" || task.Property(disableCheckpointProperty{}) != nil {
+				return next(ctx)
+			}
+
+			codec := codec
+			if c, ok := task.Property(codecProperty{}).(Codec); ok {
+				codec = c
+			}
+
+			data, ok, err := store.Load(key)
+			if err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: load %q: %w", key, err)}
+			}
+			if ok {
+				value, err := codec.Decode(data)
+				if err != nil {
+					return taskset.Result{Err: fmt.Errorf("checkpoint: decode %q: %w", key, err)}
+				}
+				return taskset.Result{Value: value}
+			}
+
+			result := next(ctx)
+			if result.Err != nil || taskset.IsDryRun(ctx) {
+				return result
+			}
+
+			data, err = codec.Encode(result.Value)
+			if err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: encode %q: %w", key, err)}
+			}
+			if err := store.Save(key, data); err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: save %q: %w", key, err)}
+			}
+
+			return result
+		},
+	}
+}
@@ -0,0 +1,249 @@
+package checkpoint_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares/checkpoint"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleNewCheckpoint() {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "checkpoint")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := checkpoint.NewDirStore(dir)
+
+	run := func(failB bool) {
+		taskSet := taskset.NewTaskSet(
+			checkpoint.NewCheckpoint(store, checkpoint.GobCodec),
+		)
+
+		taskA := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			fmt.Println("running A")
+			return 1, nil
+		},
+			properties.WithName("A"),
+		)
+
+		taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			a := depend(ctx, taskA).Value.(int)
+			fmt.Println("running B")
+			if failB {
+				return nil, errors.New("fail")
+			}
+			return a + 1, nil
+		},
+			properties.WithName("B"),
+		)
+
+		// B's value is loaded without depending on A, so wait for A too,
+		// before the store is removed.
+		taskSet.Start(ctx)
+		taskSet.Wait(ctx)
+
+		result := taskSet.Result(ctx, taskB)
+		fmt.Println("B:", result.Value, result.Err)
+	}
+
+	run(true)
+	run(false)
+	run(false)
+
+	// Output:
+	// running A
+	// running B
+	// B: <nil> fail
+	// running B
+	// B: 2 <nil>
+	// B: 2 <nil>
+}
+
+func ExampleNewIncremental() {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "incremental")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := checkpoint.NewDirStore(dir)
+
+	run := func(input string) {
+		taskSet := taskset.NewTaskSet(
+			checkpoint.NewIncremental(store, checkpoint.GobCodec),
+		)
+
+		length := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			fmt.Println("running length")
+			return len(input), nil
+		},
+			properties.WithName("length"),
+			checkpoint.WithFingerprint(func(ctx context.Context) ([]byte, error) {
+				return []byte(input), nil
+			}),
+		)
+
+		var double *taskset.Task
+		double = taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			fmt.Println("running double")
+			return depend(ctx, length).Value.(int) * 2, nil
+		},
+			properties.WithName("double"),
+			taskset.WithDependencies(length),
+			checkpoint.WithFingerprint(func(ctx context.Context) ([]byte, error) {
+				return nil, nil
+			}),
+		)
+
+		taskSet.Start(ctx)
+		fmt.Println("result:", taskSet.Result(ctx, double).Value)
+	}
+
+	run("abc")
+	run("abc")
+	run("xyz")
+	run("abcd")
+
+	// Output:
+	// running length
+	// running double
+	// result: 6
+	// result: 6
+	// running length
+	// result: 6
+	// running length
+	// running double
+	// result: 8
+}
+
+type enteredProperty struct{}
+
+func TestNewIncremental_innerMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	// inner expects its Depend to be called only during its Run, like prometheus middleware does.
+	inner := taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			task.ModifyProperty(enteredProperty{}, func(_ interface{}) interface{} {
+				return true
+			})
+			return next(ctx)
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if task.Property(enteredProperty{}) == nil {
+				t.Errorf("Depend of %s called before its Run", properties.Name(task))
+			}
+			return next(ctx)
+		},
+	}
+
+	taskSet := taskset.NewTaskSet(
+		checkpoint.NewIncremental(checkpoint.NewDirStore(t.TempDir()), checkpoint.GobCodec),
+		inner,
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value.(int) + 1, nil
+	},
+		properties.WithName("B"),
+		taskset.WithDependencies(taskA),
+		checkpoint.WithFingerprint(func(ctx context.Context) ([]byte, error) {
+			return nil, nil
+		}),
+	)
+
+	taskSet.Start(ctx)
+	if result := taskSet.Result(ctx, taskB); result.Value != 2 {
+		t.Errorf("B = %v, want 2", result.Value)
+	}
+}
+
+func TestNewIncremental_dependOnce(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	depends := 0
+	counter := taskset.Middleware{
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			mu.Lock()
+			depends++
+			mu.Unlock()
+			return next(ctx)
+		},
+	}
+
+	taskSet := taskset.NewTaskSet(
+		counter,
+		checkpoint.NewIncremental(checkpoint.NewDirStore(t.TempDir()), checkpoint.GobCodec),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value.(int) + 1, nil
+	},
+		properties.WithName("B"),
+		taskset.WithDependencies(taskA),
+		checkpoint.WithFingerprint(func(ctx context.Context) ([]byte, error) {
+			return nil, nil
+		}),
+	)
+
+	taskSet.Start(ctx)
+	if result := taskSet.Result(ctx, taskB); result.Value != 2 {
+		t.Errorf("B = %v, want 2", result.Value)
+	}
+	taskSet.Wait(ctx)
+
+	// Hashing A's result doesn't count as another dependency of B on A.
+	if depends != 1 {
+		t.Errorf("Depend called %d times, want 1", depends)
+	}
+}
+
+func TestDirStore_Clear(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := checkpoint.NewDirStore(dir)
+	if err := store.Save("A", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear() = %v", err)
+	}
+
+	if _, ok, err := store.Load("A"); ok || err != nil {
+		t.Errorf("Load(A) after Clear() = %v, %v, want no value", ok, err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("a file the store didn't write was removed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+)
+
+// Codec encodes and decodes task values for storing.
+type Codec interface {
+	Encode(value interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+type gobCodec struct{}
+
+// GobCodec encodes values using encoding/gob. The concrete types of
+// task values must be registered using gob.Register.
+var GobCodec Codec = gobCodec{}
+
+func (gobCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+type jsonCodec struct {
+	newValue func() interface{}
+}
+
+// NewJSONCodec creates a Codec that encodes values using encoding/json.
+// newValue must return a pointer to a new value of the task value's type,
+// the pointed-to value is decoded into and returned by Decode.
+func NewJSONCodec(newValue func() interface{}) Codec {
+	return jsonCodec{newValue: newValue}
+}
+
+func (c jsonCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (c jsonCodec) Decode(data []byte) (interface{}, error) {
+	ptr := c.newValue()
+	if err := json.Unmarshal(data, ptr); err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(ptr).Elem().Interface(), nil
+}
@@ -0,0 +1,93 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DirStore is a Store that keeps each value in a separate file in a directory.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a new DirStore. The directory is created on the first Save if it doesn't exist.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+func (s *DirStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// Load implements Store.
+func (s *DirStore) Load(key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Save implements Store. The file is written and synced to disk before it replaces
+// the previous one, so an interrupted Save never leaves a partially written value.
+func (s *DirStore) Save(key string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(key))
+}
+
+// Clear removes all values from the store. Other files in the directory,
+// and the directory itself, are left in place.
+func (s *DirStore) Clear() error {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isKeyFileName(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isKeyFileName reports whether name is a file name used by path.
+func isKeyFileName(name string) bool {
+	decoded, err := hex.DecodeString(name)
+	return err == nil && len(decoded) == sha256.Size && hex.EncodeToString(decoded) == name
+}
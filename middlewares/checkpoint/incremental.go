@@ -0,0 +1,161 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/bennydictor/taskset"
+)
+
+// Fingerprint returns a digest of a task's own inputs, such as the contents of
+// the files it reads. It must change whenever the inputs change.
+type Fingerprint func(ctx context.Context) ([]byte, error)
+
+type fingerprintProperty struct{}
+
+// WithFingerprint marks a task as incremental for NewIncremental. The fingerprint
+// describes the task's own inputs, and every task whose result the task uses must be
+// declared with taskset.WithDependencies.
+func WithFingerprint(fingerprint Fingerprint) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(fingerprintProperty{}, func(_ interface{}) interface{} {
+			return fingerprint
+		})
+	}
+}
+
+type digestProperty struct{}
+
+type undeclaredDependencyProperty struct{}
+
+// NewIncremental creates a middleware that skips tasks whose inputs haven't changed
+// since they last ran, like a build system does.
+//
+// Only tasks with WithFingerprint and a non-empty Key are affected. Before such a task runs,
+// the results of the dependencies declared with taskset.WithDependencies are awaited with
+// Task.Await, and hashed together with the task's fingerprint. If store has a value saved under the same hash, the task won't run,
+// and the decoded value becomes its result instead. Otherwise the task runs, and its value is saved.
+//
+// A dependency's result is hashed by its encoded value, so if a dependency runs again,
+// but returns the same value, its dependents still won't run, provided that codec
+// always encodes equal values the same way. If a task fails, or depends
+// on tasks not declared with taskset.WithDependencies, its value is not saved.
+//
+// Since awaiting the dependencies isn't seen by Depend middlewares, NewIncremental should
+// come before middlewares that must not be held while waiting, such as concurrency limiters.
+//
+// In a dry run, NewIncremental does nothing, since hashing requires running the dependencies.
+//
+// If fingerprint, store or codec return an error, it becomes the result of the task.
+func NewIncremental(store Store, codec Codec) taskset.Middleware {
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			fingerprint, ok := task.Property(fingerprintProperty{}).(Fingerprint)
+			key := Key(task)
+			if !ok || key == "" || taskset.IsDryRun(ctx) {
+				return next(ctx)
+			}
+
+			codec := codec
+			if c, ok := task.Property(codecProperty{}).(Codec); ok {
+				codec = c
+			}
+
+			input, err := fingerprint(ctx)
+			if err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: fingerprint %q: %w", key, err)}
+			}
+
+			hash := sha256.New()
+			fmt.Fprintf(hash, "%q %x", key, input)
+			for _, dependency := range task.Dependencies() {
+				result := task.Await(ctx, dependency)
+				if result.Err != nil {
+					// The task will most likely fail too, there's nothing to cache.
+					return next(ctx)
+				}
+
+				digest, err := resultDigest(dependency, result, codec)
+				if err != nil {
+					return next(ctx)
+				}
+				fmt.Fprintf(hash, " %x", digest)
+			}
+			sum := hash.Sum(nil)
+
+			entry, ok, err := store.Load(key)
+			if err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: load %q: %w", key, err)}
+			}
+			if ok && bytes.HasPrefix(entry, sum) {
+				data := entry[len(sum):]
+				value, err := codec.Decode(data)
+				if err != nil {
+					return taskset.Result{Err: fmt.Errorf("checkpoint: decode %q: %w", key, err)}
+				}
+				setDigest(task, data)
+				return taskset.Result{Value: value}
+			}
+
+			result := next(ctx)
+			if result.Err != nil || task.Property(undeclaredDependencyProperty{}) != nil {
+				return result
+			}
+
+			data, err := codec.Encode(result.Value)
+			if err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: encode %q: %w", key, err)}
+			}
+			if err := store.Save(key, append(sum, data...)); err != nil {
+				return taskset.Result{Err: fmt.Errorf("checkpoint: save %q: %w", key, err)}
+			}
+			setDigest(task, data)
+
+			return result
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if task.Property(fingerprintProperty{}) == nil {
+				return next(ctx)
+			}
+
+			declared := false
+			for _, d := range task.Dependencies() {
+				if d == dependency {
+					declared = true
+					break
+				}
+			}
+			if !declared {
+				task.ModifyProperty(undeclaredDependencyProperty{}, func(_ interface{}) interface{} {
+					return struct{}{}
+				})
+			}
+
+			return next(ctx)
+		},
+	}
+}
+
+func setDigest(task *taskset.Task, data []byte) {
+	digest := sha256.Sum256(data)
+	task.ModifyProperty(digestProperty{}, func(_ interface{}) interface{} {
+		return digest[:]
+	})
+}
+
+// resultDigest returns the digest of the dependency's value saved by NewIncremental,
+// or encodes the value if the dependency isn't incremental.
+func resultDigest(dependency *taskset.Task, result taskset.Result, codec Codec) ([]byte, error) {
+	if digest, ok := dependency.Property(digestProperty{}).([]byte); ok {
+		return digest, nil
+	}
+
+	data, err := codec.Encode(result.Value)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
@@ -0,0 +1,223 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+)
+
+// ChromeTrace provides a middleware that records when each task runs and waits
+// for its dependencies, and makes this information available in Chrome Trace Event
+// format, which can be opened in chrome://tracing or Perfetto.
+//
+// Each task gets its own track, with a slice for the task's run, and its ID from GraphNode.ID
+// in the slice's args. Tasks are grouped into processes by their TaskSet, so the tasks of
+// a child TaskSet created by NewSub are grouped under a process named after its parent task.
+// Unnamed tasks are named by their position in the tree of task sets, like GraphNode.Label.
+// Each depend() call
+// is an async slice on the task's track, because a task may wait for several dependencies
+// at once, e.g. using SyncGroup, and such waits don't nest. Flow arrows link the end
+// of each dependency to the task's slice at the moment the depend() call returned.
+type ChromeTrace struct {
+	sync.Mutex
+	epoch time.Time
+	order []*taskset.Task
+	info  map[*taskset.Task]*traceRecord
+}
+
+type traceRecord struct {
+	start, end time.Time
+	err        error
+	waits      []*traceWait
+}
+
+type traceWait struct {
+	dependency *taskset.Task
+	start, end time.Time
+}
+
+// NewChromeTrace creates a new ChromeTrace.
+func NewChromeTrace() *ChromeTrace {
+	return &ChromeTrace{
+		info: make(map[*taskset.Task]*traceRecord),
+	}
+}
+
+// Middleware provides the taskset.Middleware.
+func (c *ChromeTrace) Middleware() taskset.Middleware {
+	return taskset.Middleware{
+		Run:    c.run,
+		Depend: c.depend,
+	}
+}
+
+func (c *ChromeTrace) run(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	r := &traceRecord{start: time.Now()}
+
+	c.Lock()
+	if c.epoch.IsZero() {
+		c.epoch = r.start
+	}
+	c.order = append(c.order, task)
+	c.info[task] = r
+	c.Unlock()
+
+	result := next(ctx)
+
+	c.Lock()
+	defer c.Unlock()
+
+	r.end = time.Now()
+	r.err = result.Err
+
+	return result
+}
+
+func (c *ChromeTrace) depend(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	w := &traceWait{dependency: dependency, start: time.Now()}
+
+	c.Lock()
+	r, ok := c.info[task]
+	if ok {
+		r.waits = append(r.waits, w)
+	}
+	c.Unlock()
+
+	result := next(ctx)
+
+	c.Lock()
+	defer c.Unlock()
+
+	w.end = time.Now()
+
+	return result
+}
+
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  *float64               `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	ID   int                    `json:"id,omitempty"`
+	BP   string                 `json:"bp,omitempty"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+type traceFile struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// Write writes the recorded trace in Chrome Trace Event format to an io.Writer.
+// Tasks that haven't finished yet are written as if they finished at the time of the call.
+func (c *ChromeTrace) Write(w io.Writer) error {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	micros := func(t time.Time) float64 {
+		if t.IsZero() {
+			t = now
+		}
+		return float64(t.Sub(c.epoch).Nanoseconds()) / 1e3
+	}
+	slice := func(name, cat string, pid, tid int, start, end time.Time, args map[string]interface{}) traceEvent {
+		dur := micros(end) - micros(start)
+		return traceEvent{Name: name, Cat: cat, Ph: "X", Ts: micros(start), Dur: &dur, Pid: pid, Tid: tid, Args: args}
+	}
+
+	tids := make(map[*taskset.Task]int, len(c.order))
+	for i, task := range c.order {
+		tids[task] = i + 1
+	}
+
+	file := traceFile{
+		TraceEvents:     []traceEvent{},
+		DisplayTimeUnit: "ms",
+	}
+
+	// Top-level TaskSets are numbered in the order their first task started, like in GraphRecorder.
+	sets := make(map[*taskset.TaskSet]int)
+	pids := make(map[*taskset.TaskSet]int)
+	for _, task := range c.order {
+		if _, ok := pids[task.TaskSet()]; ok {
+			continue
+		}
+
+		p := len(pids) + 1
+		pids[task.TaskSet()] = p
+
+		var name string
+		if parent := task.Parent(); parent != nil {
+			name = "task " + taskLabel(parent)
+		} else {
+			sets[task.TaskSet()] = len(sets)
+			name = task.TaskSet().Name()
+			if name == "" {
+				name = "task set"
+			}
+		}
+		file.TraceEvents = append(file.TraceEvents,
+			traceEvent{Name: "process_name", Ph: "M", Pid: p, Args: map[string]interface{}{"name": name}},
+			traceEvent{Name: "process_sort_index", Ph: "M", Pid: p, Args: map[string]interface{}{"sort_index": p}},
+		)
+	}
+
+	flowID, waitID := 0, 0
+	for _, task := range c.order {
+		r, pid, tid := c.info[task], pids[task.TaskSet()], tids[task]
+		name := "task " + taskLabel(task)
+
+		file.TraceEvents = append(file.TraceEvents, traceEvent{
+			Name: "thread_name",
+			Ph:   "M",
+			Pid:  pid,
+			Tid:  tid,
+			Args: map[string]interface{}{"name": name},
+		})
+
+		args := map[string]interface{}{"id": taskID(sets[topLevelSet(task)], task)}
+		if r.err != nil {
+			args["error"] = r.err.Error()
+		}
+		file.TraceEvents = append(file.TraceEvents, slice(name, "task", pid, tid, r.start, r.end, args))
+
+		for _, wait := range r.waits {
+			waitID++
+			name := "depend on task " + taskLabel(wait.dependency)
+			file.TraceEvents = append(file.TraceEvents,
+				traceEvent{Name: name, Cat: "depend", Ph: "b", Ts: micros(wait.start), Pid: pid, Tid: tid, ID: waitID},
+				traceEvent{Name: name, Cat: "depend", Ph: "e", Ts: micros(wait.end), Pid: pid, Tid: tid, ID: waitID},
+			)
+
+			d, ok := c.info[wait.dependency]
+			if !ok || d.end.IsZero() || wait.end.IsZero() {
+				continue
+			}
+
+			// The flow starts slightly before the dependency's end, so that it binds to its slice.
+			flowID++
+			file.TraceEvents = append(file.TraceEvents,
+				traceEvent{Name: "depend", Cat: "depend", Ph: "s", Ts: micros(d.end) - 1, Pid: pids[wait.dependency.TaskSet()], Tid: tids[wait.dependency], ID: flowID},
+				traceEvent{Name: "depend", Cat: "depend", Ph: "f", BP: "e", Ts: micros(wait.end), Pid: pid, Tid: tid, ID: flowID},
+			)
+		}
+	}
+
+	return json.NewEncoder(w).Encode(file)
+}
+
+// String returns the recorded trace in Chrome Trace Event format as a string.
+func (c *ChromeTrace) String() string {
+	var buf bytes.Buffer
+	_ = c.Write(&buf)
+	return buf.String()
+}
@@ -0,0 +1,80 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleChromeTrace() {
+	ctx := context.Background()
+
+	chromeTrace := middlewares.NewChromeTrace()
+	taskSet := taskset.NewTaskSet(
+		chromeTrace.Middleware(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	// The tasks of B's child TaskSet are grouped under B, and the unnamed one is #1.0.
+	// B depends on it twice: once to wait for the child's eager tasks, and once for its result.
+	taskSet.NewSub(func(child *taskset.TaskSet) *taskset.Task {
+		return child.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return depend(ctx, taskA).Value.(int) + 1, nil
+		})
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	var trace struct {
+		TraceEvents []struct {
+			Name string
+			Ph   string
+			Pid  int
+			Tid  int
+			Args map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(chromeTrace.String()), &trace); err != nil {
+		panic(err)
+	}
+
+	for _, e := range trace.TraceEvents {
+		fmt.Println(e.Ph, e.Pid, e.Tid, e.Name, e.Args)
+	}
+
+	// Output:
+	// M 1 0 process_name map[name:task set]
+	// M 1 0 process_sort_index map[sort_index:1]
+	// M 2 0 process_name map[name:task B]
+	// M 2 0 process_sort_index map[sort_index:2]
+	// M 1 1 thread_name map[name:task B]
+	// X 1 1 task B map[id:task_1]
+	// b 1 1 depend on task #1.0 map[]
+	// e 1 1 depend on task #1.0 map[]
+	// s 2 2 depend map[]
+	// f 1 1 depend map[]
+	// b 1 1 depend on task #1.0 map[]
+	// e 1 1 depend on task #1.0 map[]
+	// s 2 2 depend map[]
+	// f 1 1 depend map[]
+	// M 2 2 thread_name map[name:task #1.0]
+	// X 2 2 task #1.0 map[id:task_1_0]
+	// b 2 2 depend on task A map[]
+	// e 2 2 depend on task A map[]
+	// s 1 3 depend map[]
+	// f 2 2 depend map[]
+	// M 1 3 thread_name map[name:task A]
+	// X 1 3 task A map[id:task_0]
+}
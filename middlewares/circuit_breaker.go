@@ -0,0 +1,215 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+)
+
+// ErrCircuitOpen is the result of a task that wasn't run because its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is a state of a circuit breaker's circuit.
+type CircuitState int
+
+const (
+	// CircuitClosed means the tasks are run as usual.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the tasks are not run, and fail with ErrCircuitOpen instead.
+	CircuitOpen
+	// CircuitHalfOpen means a single trial task is run, and others fail with ErrCircuitOpen.
+	// If the trial task succeeds, the circuit is closed, otherwise it's opened again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker.
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that open a circuit.
+	// If FailureThreshold is zero, 5 is used.
+	FailureThreshold int
+	// OpenTimeout is the time after which an open circuit becomes half-open.
+	// If OpenTimeout is zero, 10 seconds is used.
+	OpenTimeout time.Duration
+	// Key returns the key of a task's circuit. Tasks with the same key share a circuit.
+	// Tasks with an empty key are not guarded by any circuit.
+	// If Key is nil, properties.Group is used.
+	Key func(task *taskset.Task) string
+	// OnStateChange, if not nil, is called on each circuit state change.
+	// It's called with the circuit breaker locked, so it must not call the circuit breaker's methods.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+// CircuitBreaker provides a middleware that stops running the tasks of a group,
+// when they keep failing, e.g. because the upstream they call is down.
+// A CircuitBreaker is meant to be shared by many task sets.
+type CircuitBreaker struct {
+	sync.Mutex
+	opts     CircuitBreakerOptions
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker.
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.OpenTimeout == 0 {
+		opts.OpenTimeout = 10 * time.Second
+	}
+	if opts.Key == nil {
+		opts.Key = properties.Group
+	}
+
+	return &CircuitBreaker{
+		opts:     opts,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// State returns the current state of the circuit with the given key.
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	cb.Lock()
+	defer cb.Unlock()
+
+	c, ok := cb.circuits[key]
+	if !ok {
+		return CircuitClosed
+	}
+	cb.refresh(key, c)
+	return c.state
+}
+
+// Middleware provides the taskset.Middleware.
+//
+// While a task's circuit is open, the task is not run, and its result is ErrCircuitOpen.
+// A task counts as failed if it returns an error. If the task's context was cancelled,
+// the error doesn't count, and neither does the task, e.g. a cancelled trial of a half-open
+// circuit leaves it half-open for the next trial.
+// Circuits are neither checked nor updated in a dry run, see taskset.DryRun.
+func (cb *CircuitBreaker) Middleware() taskset.Middleware {
+	return taskset.Middleware{
+		Run: cb.run,
+	}
+}
+
+func (cb *CircuitBreaker) run(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	if taskset.IsDryRun(ctx) {
+		return next(ctx)
+	}
+
+	key := cb.opts.Key(task)
+	if key == "" {
+		return next(ctx)
+	}
+
+	cb.Lock()
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[key] = c
+	}
+	cb.refresh(key, c)
+
+	trial := false
+	switch c.state {
+	case CircuitOpen:
+		cb.Unlock()
+		return taskset.Result{Err: ErrCircuitOpen}
+	case CircuitHalfOpen:
+		if c.trial {
+			cb.Unlock()
+			return taskset.Result{Err: ErrCircuitOpen}
+		}
+		c.trial = true
+		trial = true
+	}
+	cb.Unlock()
+
+	if trial {
+		// The trial is over even if the task panics.
+		defer func() {
+			cb.Lock()
+			defer cb.Unlock()
+			c.trial = false
+		}()
+	}
+
+	result := next(ctx)
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	if result.Err != nil && ctx.Err() != nil {
+		// Cancellation says nothing about the health of the circuit.
+		return result
+	}
+
+	if trial {
+		if result.Err != nil {
+			cb.open(key, c)
+		} else {
+			c.failures = 0
+			cb.setState(key, c, CircuitClosed)
+		}
+		return result
+	}
+
+	if c.state != CircuitClosed {
+		return result
+	}
+	if result.Err == nil {
+		c.failures = 0
+		return result
+	}
+
+	c.failures++
+	if c.failures >= cb.opts.FailureThreshold {
+		cb.open(key, c)
+	}
+
+	return result
+}
+
+func (cb *CircuitBreaker) refresh(key string, c *circuit) {
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= cb.opts.OpenTimeout {
+		cb.setState(key, c, CircuitHalfOpen)
+	}
+}
+
+func (cb *CircuitBreaker) open(key string, c *circuit) {
+	c.failures = 0
+	c.openedAt = time.Now()
+	cb.setState(key, c, CircuitOpen)
+}
+
+func (cb *CircuitBreaker) setState(key string, c *circuit, state CircuitState) {
+	from := c.state
+	c.state = state
+	if from != state && cb.opts.OnStateChange != nil {
+		cb.opts.OnStateChange(key, from, state)
+	}
+}
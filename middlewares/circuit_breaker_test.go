@@ -0,0 +1,143 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleNewCircuitBreaker() {
+	ctx := context.Background()
+
+	breaker := middlewares.NewCircuitBreaker(middlewares.CircuitBreakerOptions{
+		FailureThreshold: 2,
+		OpenTimeout:      100 * time.Millisecond,
+		OnStateChange: func(key string, from, to middlewares.CircuitState) {
+			fmt.Printf("circuit %s: %s -> %s\n", key, from, to)
+		},
+	})
+
+	// Each request runs its own task set, sharing the circuit breaker.
+	request := func(err error) {
+		taskSet := taskset.NewTaskSet(breaker.Middleware())
+
+		task := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			fmt.Println("calling db")
+			return nil, err
+		},
+			properties.WithGroup("db"),
+		)
+
+		taskSet.Start(ctx)
+		fmt.Println("result:", taskSet.Result(ctx, task).Err)
+	}
+
+	down := errors.New("db is down")
+	request(down)
+	request(down)
+	request(down)
+
+	time.Sleep(100 * time.Millisecond)
+	request(nil)
+
+	// Output:
+	// calling db
+	// result: db is down
+	// calling db
+	// circuit db: closed -> open
+	// result: db is down
+	// result: circuit breaker is open
+	// circuit db: open -> half-open
+	// calling db
+	// circuit db: half-open -> closed
+	// result: <nil>
+}
+
+// newHalfOpenBreaker returns a CircuitBreaker whose circuit for the "db" group is half-open.
+func newHalfOpenBreaker(t *testing.T) *middlewares.CircuitBreaker {
+	breaker := middlewares.NewCircuitBreaker(middlewares.CircuitBreakerOptions{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Nanosecond,
+	})
+
+	run(breaker.Middleware(), func(context.Context, taskset.Depend) (interface{}, error) {
+		return nil, errors.New("fail")
+	}, properties.WithGroup("db"))
+	time.Sleep(time.Millisecond)
+
+	if state := breaker.State("db"); state != middlewares.CircuitHalfOpen {
+		t.Fatalf("state = %v, want %v", state, middlewares.CircuitHalfOpen)
+	}
+	return breaker
+}
+
+func TestCircuitBreaker_cancelledTrial(t *testing.T) {
+	breaker := newHalfOpenBreaker(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	taskSet := taskset.NewTaskSet(breaker.Middleware())
+	task := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, ctx.Err()
+	}, properties.WithGroup("db"))
+	taskSet.Start(ctx)
+	taskSet.Wait(context.Background())
+
+	if err := taskSet.Result(context.Background(), task).Err; err != context.Canceled {
+		t.Fatalf("result = %v, want %v", err, context.Canceled)
+	}
+	if state := breaker.State("db"); state != middlewares.CircuitHalfOpen {
+		t.Errorf("state after a cancelled trial = %v, want %v", state, middlewares.CircuitHalfOpen)
+	}
+}
+
+func TestCircuitBreaker_panickedTrial(t *testing.T) {
+	breaker := newHalfOpenBreaker(t)
+
+	recoverPanic := middlewares.NewRecover(func(v interface{}) taskset.Result {
+		return taskset.Result{Err: fmt.Errorf("panic: %v", v)}
+	})
+	taskSet := taskset.NewTaskSet(recoverPanic, breaker.Middleware())
+	task := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		panic("oops")
+	}, properties.WithGroup("db"))
+	taskSet.Start(context.Background())
+	taskSet.Result(context.Background(), task)
+
+	// The panicked trial didn't decide anything, and another trial is allowed.
+	result := run(breaker.Middleware(), succeed, properties.WithGroup("db"))
+	if result.Err != nil {
+		t.Errorf("result of the next trial = %v, want <nil>", result.Err)
+	}
+	if state := breaker.State("db"); state != middlewares.CircuitClosed {
+		t.Errorf("state = %v, want %v", state, middlewares.CircuitClosed)
+	}
+}
+
+func TestCircuitBreaker_emptyKey(t *testing.T) {
+	breaker := middlewares.NewCircuitBreaker(middlewares.CircuitBreakerOptions{
+		FailureThreshold: 2,
+	})
+	fail := func(context.Context, taskset.Depend) (interface{}, error) {
+		return nil, errors.New("fail")
+	}
+
+	for i := 0; i < 3; i++ {
+		run(breaker.Middleware(), fail)
+	}
+
+	// Ungrouped tasks of unrelated task sets don't share a circuit.
+	if result := run(breaker.Middleware(), succeed); result.Err != nil {
+		t.Errorf("result = %v, want <nil>", result.Err)
+	}
+	if state := breaker.State(""); state != middlewares.CircuitClosed {
+		t.Errorf("state = %v, want %v", state, middlewares.CircuitClosed)
+	}
+}
@@ -3,35 +3,68 @@ package middlewares
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/bennydictor/taskset"
 	"golang.org/x/sync/semaphore"
 )
 
+// ContextLocker is a sync.Locker that can also be locked with a context.
+// Concurrency limiter uses LockContext to stop waiting for a lock when a task's context is done.
+type ContextLocker interface {
+	sync.Locker
+
+	// LockContext locks the lock, blocking until it's available or ctx is done.
+	// If ctx is done first, LockContext returns ctx.Err() without locking.
+	LockContext(ctx context.Context) error
+}
+
 type semaphoreLocker struct {
 	*semaphore.Weighted
+	weight int64
 }
 
-// NewSemaphore returns a sync.Locker that can be locked up to n times concurrently.
-func NewSemaphore(n int64) sync.Locker {
-	return semaphoreLocker{semaphore.NewWeighted(n)}
+// NewSemaphore returns a ContextLocker that can be locked up to n times concurrently.
+// With concurrency limiter, a task can lock it several times at once using WithWeight.
+func NewSemaphore(n int64) ContextLocker {
+	return semaphoreLocker{semaphore.NewWeighted(n), 1}
 }
 
 // Lock implements sync.Locker.
 func (s semaphoreLocker) Lock() {
-	_ = s.Acquire(context.Background(), 1)
+	_ = s.Acquire(context.Background(), s.weight)
+}
+
+// LockContext implements ContextLocker.
+func (s semaphoreLocker) LockContext(ctx context.Context) error {
+	return s.Acquire(ctx, s.weight)
 }
 
 // Unlock implements sync.Locker.
 func (s semaphoreLocker) Unlock() {
-	s.Release(1)
+	s.Release(s.weight)
 }
 
-type noopLocker struct{}
+// contextLocker adapts a sync.Locker that can't be locked with a context.
+// It only checks the context before locking.
+type contextLocker struct {
+	sync.Locker
+}
 
-func (n noopLocker) Lock() {}
+func (l contextLocker) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.Lock()
+	return nil
+}
 
-func (n noopLocker) Unlock() {}
+func toContextLocker(lock sync.Locker) ContextLocker {
+	if lock, ok := lock.(ContextLocker); ok {
+		return lock
+	}
+	return contextLocker{lock}
+}
 
 type lockProperty struct{}
 
@@ -44,7 +77,71 @@ func WithLock(lock sync.Locker) taskset.Property {
 	}
 }
 
-type dependCountProperty struct{}
+// multiLocker locks several locks in order, and unlocks them in reverse order.
+// As long as all tasks lock the same locks in the same order, they don't deadlock.
+type multiLocker []ContextLocker
+
+func (m multiLocker) Lock() {
+	for _, lock := range m {
+		lock.Lock()
+	}
+}
+
+func (m multiLocker) LockContext(ctx context.Context) error {
+	for i, lock := range m {
+		if err := lock.LockContext(ctx); err != nil {
+			multiLocker(m[:i]).Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiLocker) Unlock() {
+	for i := len(m) - 1; i >= 0; i-- {
+		m[i].Unlock()
+	}
+}
+
+type weightProperty struct{}
+
+// WithWeight makes a task lock the semaphores created by NewSemaphore, or by keyed
+// concurrency limiter, n times at once, e.g. because it uses n times more resources
+// than other tasks. Other locks are locked once regardless of weight.
+// A task whose weight is more than a semaphore's size never gets to run,
+// unless its context is cancelled.
+func WithWeight(n int64) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(weightProperty{}, func(_ interface{}) interface{} {
+			return n
+		})
+	}
+}
+
+func weighted(lock ContextLocker, task *taskset.Task) ContextLocker {
+	s, ok := lock.(semaphoreLocker)
+	if !ok {
+		return lock
+	}
+	if weight, ok := task.Property(weightProperty{}).(int64); ok {
+		s.weight = weight
+	}
+	return s
+}
+
+// limiterStateProperty is the key of a concurrency limiter's state of a task.
+// Each limiter has its own id, so that stacked limiters don't overwrite each other's state.
+type limiterStateProperty struct {
+	id uint64
+}
+
+var lastLimiterID uint64
+
+type limiterState struct {
+	sync.Mutex
+	lock        ContextLocker
+	dependCount uint
+}
 
 // NewConcurrencyLimiter creates a middleware used to limit a task set's concurrency.
 // Concurrency limiter will lock and unlock the provided lock before and after
@@ -53,58 +150,92 @@ type dependCountProperty struct{}
 //
 // If lock is nil, concurrency limiter will instead use a lock provided
 // for each task separately using WithLock. If no lock was provided for a task,
-// nothing is locked for that task.
+// nothing is locked for that task. In either case, the read and write locks provided
+// using WithReadLock and WithWriteLock are locked as well.
+//
+// If the lock is a ContextLocker, concurrency limiter waits for it using the task's context.
+// If the context is done before the task gets the lock, the task is not run, and its result
+// is ctx.Err(). Other locks, like *sync.Mutex, are waited for regardless of the context.
+// After a depend() call, the task always waits to get the lock back regardless of the context,
+// so that it never runs without the lock.
+// In a dry run, see taskset.DryRun, nothing is locked.
 //
-// If you want to run all tasks sequentially, use &sync.Mutex{}.
+// If you want to run all tasks sequentially, use &sync.Mutex{}, or NewSemaphore(1) to make it cancellable.
 // If you want to limit the number of parallel tasks, use NewSemaphore.
 // If you want a subset of tasks to be mutually exclusive, use WithLock.
+// If you want some tasks to share a resource, and others to have it exclusively, use WithReadLock and WithWriteLock.
+// If you want heavy tasks to take several slots of a semaphore, use WithWeight.
+// If you want to limit the number of parallel tasks per group, use NewKeyedConcurrencyLimiter.
+// If you want the limit to be tuned at runtime, use NewAdaptiveLimiter.
+// If you want to share the limit fairly between tenants, use NewFairLimiter.
 func NewConcurrencyLimiter(lock sync.Locker) taskset.Middleware {
-	getLock := func(task *taskset.Task) sync.Locker {
+	return newConcurrencyLimiter(func(task *taskset.Task) (ContextLocker, func(taskset.Result)) {
+		var locks multiLocker
+
 		if lock != nil {
-			return lock
+			locks = append(locks, weighted(toContextLocker(lock), task))
+		} else if taskLock := task.Property(lockProperty{}); taskLock != nil {
+			locks = append(locks, weighted(toContextLocker(taskLock.(sync.Locker)), task))
 		}
 
-		if taskLock := task.Property(lockProperty{}); taskLock != nil {
-			return taskLock.(sync.Locker)
-		}
+		return append(locks, rwLocks(task)...), func(taskset.Result) {}
+	})
+}
 
-		return noopLocker{}
-	}
+// newConcurrencyLimiter creates a concurrency limiter, which locks the lock returned by getLock for each task.
+// The returned function is called with the task's result once the task is finished, and the lock is no longer used.
+func newConcurrencyLimiter(getLock func(task *taskset.Task) (ContextLocker, func(taskset.Result))) taskset.Middleware {
+	stateKey := limiterStateProperty{atomic.AddUint64(&lastLimiterID, 1)}
 
 	return taskset.Middleware{
-		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-			task.ModifyProperty(dependCountProperty{}, func(_ interface{}) interface{} {
-				return uint(0)
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) (result taskset.Result) {
+			if taskset.IsDryRun(ctx) {
+				return next(ctx)
+			}
+
+			lock, release := getLock(task)
+			defer func() { release(result) }()
+
+			if err := lock.LockContext(ctx); err != nil {
+				return taskset.Result{Err: err}
+			}
+
+			state := &limiterState{lock: lock}
+			task.ModifyProperty(stateKey, func(_ interface{}) interface{} {
+				return state
 			})
 
-			getLock(task).Lock()
-			defer getLock(task).Unlock()
+			defer lock.Unlock()
 
 			return next(ctx)
 		},
 
 		Depend: func(ctx context.Context, task, _ *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-			var dependCount uint
+			state, ok := task.Property(stateKey).(*limiterState)
+			if !ok {
+				return next(ctx)
+			}
+			lock := state.lock
 
-			task.ModifyProperty(dependCountProperty{}, func(value interface{}) interface{} {
-				dependCount = value.(uint)
-				return dependCount + 1
-			})
-			if dependCount == 0 {
-				getLock(task).Unlock()
+			// The lock is unlocked and locked with the state locked, so that concurrent
+			// depend() calls of the same task don't interleave them.
+			state.Lock()
+			if state.dependCount == 0 {
+				lock.Unlock()
 			}
+			state.dependCount++
+			state.Unlock()
 
-			defer func() {
-				task.ModifyProperty(dependCountProperty{}, func(value interface{}) interface{} {
-					dependCount = value.(uint) - 1
-					return dependCount
-				})
-				if dependCount == 0 {
-					getLock(task).Lock()
-				}
-			}()
+			result := next(ctx)
 
-			return next(ctx)
+			state.Lock()
+			defer state.Unlock()
+			state.dependCount--
+			if state.dependCount == 0 {
+				lock.Lock()
+			}
+
+			return result
 		},
 	}
 }
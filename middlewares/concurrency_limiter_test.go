@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"testing"
 	"time"
 
 	"github.com/bennydictor/taskset"
@@ -121,3 +123,117 @@ func ExampleNewConcurrencyLimiter_mutualExclusion() {
 	fmt.Printf("total time: %.0fs\n", totalTime.Seconds())
 	// Output: total time: 4s
 }
+
+func ExampleNewConcurrencyLimiter_cancel() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewConcurrencyLimiter(middlewares.NewSemaphore(1)),
+	)
+
+	var mu sync.Mutex
+	var ran []string
+
+	run := func(name string) taskset.RunFunc {
+		return func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			time.Sleep(200 * time.Millisecond)
+			return nil, nil
+		}
+	}
+
+	taskA := taskSet.New(run("A"))
+	taskB := taskSet.New(run("B"))
+
+	taskSet.Start(ctx)
+	taskSet.Wait(context.Background())
+
+	// Only one of the tasks gets the lock before the context times out,
+	// the other one stops waiting for it and isn't run.
+	fmt.Println("tasks run:", len(ran))
+	for _, err := range []error{
+		taskSet.Result(context.Background(), taskA).Err,
+		taskSet.Result(context.Background(), taskB).Err,
+	} {
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Output:
+	// tasks run: 1
+	// context deadline exceeded
+}
+
+func TestNewConcurrencyLimiter_cancelledDepend(t *testing.T) {
+	ctx := context.Background()
+
+	var inside int32
+	enter := func() {
+		if atomic.AddInt32(&inside, 1) > 1 {
+			t.Error("tasks with the same lock overlap")
+		}
+	}
+	exit := func() {
+		atomic.AddInt32(&inside, -1)
+	}
+
+	bCtx, cancelB := context.WithCancel(ctx)
+	bLocked, cLocked, bDone := make(chan struct{}), make(chan struct{}), make(chan struct{})
+
+	// C waits for the lock only after B gets it.
+	var taskC *taskset.Task
+	holdC := taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if task == taskC {
+				<-bLocked
+			}
+			return next(ctx)
+		},
+	}
+
+	mu := middlewares.NewSemaphore(1)
+	taskSet := taskset.NewTaskSet(
+		holdC,
+		middlewares.NewConcurrencyLimiter(nil),
+	)
+
+	// A cancels B's depend() call while C has the lock.
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		<-cLocked
+		cancelB()
+		return nil, nil
+	})
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		defer close(bDone)
+		enter()
+		close(bLocked)
+		exit()
+		depend(bCtx, taskA)
+		enter()
+		exit()
+		return nil, nil
+	},
+		middlewares.WithLock(mu),
+	)
+
+	taskC = taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		enter()
+		defer exit()
+		close(cLocked)
+		// B must not continue until C is done.
+		select {
+		case <-bDone:
+		case <-time.After(100 * time.Millisecond):
+		}
+		return nil, nil
+	},
+		middlewares.WithLock(mu),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+}
@@ -0,0 +1,287 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+)
+
+// CriticalPath provides a middleware that records when each task runs and waits
+// for its dependencies, and computes the critical path of the execution from it:
+// the chain of tasks that determined the total execution time.
+type CriticalPath struct {
+	sync.Mutex
+	order []*taskset.Task
+	info  map[*taskset.Task]*taskRecord
+}
+
+type taskRecord struct {
+	start, end  time.Time
+	selfTime    time.Duration
+	resumed     time.Time
+	dependCount uint
+	waits       []*waitRecord
+}
+
+type waitRecord struct {
+	task, dependency *taskset.Task
+	start, end       time.Time
+	resumed          time.Time
+}
+
+// NewCriticalPath creates a new CriticalPath.
+func NewCriticalPath() *CriticalPath {
+	return &CriticalPath{
+		info: make(map[*taskset.Task]*taskRecord),
+	}
+}
+
+// Middleware provides the taskset.Middleware.
+func (c *CriticalPath) Middleware() taskset.Middleware {
+	return taskset.Middleware{
+		Run:    c.run,
+		Depend: c.depend,
+	}
+}
+
+func (c *CriticalPath) run(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	now := time.Now()
+
+	c.Lock()
+	c.order = append(c.order, task)
+	c.info[task] = &taskRecord{start: now, resumed: now}
+	c.Unlock()
+
+	result := next(ctx)
+	now = time.Now()
+
+	c.Lock()
+	defer c.Unlock()
+
+	r := c.info[task]
+	r.end = now
+	r.selfTime += now.Sub(r.resumed)
+
+	return result
+}
+
+func (c *CriticalPath) depend(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	w := &waitRecord{task: task, dependency: dependency}
+
+	c.Lock()
+	r, ok := c.info[task]
+	if ok {
+		w.start = time.Now()
+		if r.dependCount == 0 {
+			r.selfTime += w.start.Sub(r.resumed)
+		}
+		r.dependCount++
+		r.waits = append(r.waits, w)
+	}
+	c.Unlock()
+
+	result := next(ctx)
+	if !ok {
+		return result
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	w.end = time.Now()
+	r.dependCount--
+	if r.dependCount == 0 {
+		r.resumed = w.end
+		// The task resumes its own work only when it's not waiting for anything.
+		for _, w := range r.waits {
+			if w.resumed.IsZero() && !w.end.IsZero() {
+				w.resumed = r.resumed
+			}
+		}
+	}
+
+	return result
+}
+
+// CriticalPathTask describes a task on the critical path.
+type CriticalPathTask struct {
+	Task *taskset.Task
+	// Start and End are the times the task started and finished running.
+	Start, End time.Time
+	// SelfTime is the time the task spent running, excluding the time spent in depend().
+	SelfTime time.Duration
+	// WaitTime is the time the task spent in depend().
+	WaitTime time.Duration
+}
+
+// CriticalPathReport is the critical path of a recorded execution.
+type CriticalPathReport struct {
+	// Total is the time from the start of the first task to the end of the last one.
+	Total time.Duration
+	// Path is the critical path, in the order the tasks started. Each task on the path,
+	// except the first one, was blocked waiting for the previous one.
+	Path []CriticalPathTask
+	// Slack is, for each recorded task, the time it could have taken longer without
+	// increasing the total time. Tasks on the critical path have zero slack.
+	Slack map[*taskset.Task]time.Duration
+}
+
+// Report computes the critical path of the tasks recorded so far.
+// Tasks that haven't finished yet are ignored.
+func (c *CriticalPath) Report() CriticalPathReport {
+	c.Lock()
+	defer c.Unlock()
+
+	report := CriticalPathReport{
+		Slack: make(map[*taskset.Task]time.Duration),
+	}
+
+	var first, last time.Time
+	var lastTask *taskset.Task
+	waitsOn := make(map[*taskset.Task][]*waitRecord)
+	for _, task := range c.order {
+		r := c.info[task]
+		if r.end.IsZero() {
+			continue
+		}
+		if first.IsZero() || r.start.Before(first) {
+			first = r.start
+		}
+		if lastTask == nil || r.end.After(last) {
+			last, lastTask = r.end, task
+		}
+		for _, w := range r.waits {
+			if !w.resumed.IsZero() {
+				waitsOn[w.dependency] = append(waitsOn[w.dependency], w)
+			}
+		}
+	}
+	if lastTask == nil {
+		return report
+	}
+	report.Total = last.Sub(first)
+
+	var slack func(task *taskset.Task) time.Duration
+	slack = func(task *taskset.Task) time.Duration {
+		if s, ok := report.Slack[task]; ok {
+			return s
+		}
+
+		r := c.info[task]
+		s := last.Sub(r.end)
+		for _, w := range waitsOn[task] {
+			if _, ok := c.info[w.task]; !ok || c.info[w.task].end.IsZero() {
+				continue
+			}
+			ws := w.resumed.Sub(r.end)
+			if ws < 0 {
+				ws = 0
+			}
+			ws += slack(w.task)
+			if ws < s {
+				s = ws
+			}
+		}
+
+		report.Slack[task] = s
+		return s
+	}
+
+	for _, task := range c.order {
+		if !c.info[task].end.IsZero() {
+			slack(task)
+		}
+	}
+
+	for task := lastTask; task != nil; {
+		r := c.info[task]
+		report.Path = append(report.Path, CriticalPathTask{
+			Task:     task,
+			Start:    r.start,
+			End:      r.end,
+			SelfTime: r.selfTime,
+			WaitTime: r.end.Sub(r.start) - r.selfTime,
+		})
+
+		// Continue with the last dependency the task had to wait for.
+		var next *taskset.Task
+		for _, w := range r.waits {
+			d, ok := c.info[w.dependency]
+			if !ok || d.end.IsZero() || !d.end.After(w.start) {
+				continue
+			}
+			if next == nil || d.end.After(c.info[next].end) {
+				next = w.dependency
+			}
+		}
+		task = next
+	}
+
+	for i, j := 0, len(report.Path)-1; i < j; i, j = i+1, j-1 {
+		report.Path[i], report.Path[j] = report.Path[j], report.Path[i]
+	}
+
+	return report
+}
+
+// Write writes a human-readable report of the critical path to an io.Writer,
+// followed by the slack of every other task, from the smallest to the largest.
+func (r CriticalPathReport) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "total: %v\ncritical path:\n", r.Total)
+	if err != nil {
+		return err
+	}
+
+	onPath := make(map[*taskset.Task]struct{})
+	for _, t := range r.Path {
+		onPath[t.Task] = struct{}{}
+		_, err = fmt.Fprintf(w, "    task %s: self %v, wait %v\n", taskLabel(t.Task), t.SelfTime, t.WaitTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	var others []*taskset.Task
+	for task := range r.Slack {
+		if _, ok := onPath[task]; !ok {
+			others = append(others, task)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		if r.Slack[others[i]] != r.Slack[others[j]] {
+			return r.Slack[others[i]] < r.Slack[others[j]]
+		}
+		return taskLabel(others[i]) < taskLabel(others[j])
+	})
+
+	if len(others) == 0 {
+		return nil
+	}
+
+	_, err = io.WriteString(w, "slack:\n")
+	if err != nil {
+		return err
+	}
+
+	for _, task := range others {
+		_, err = fmt.Fprintf(w, "    task %s: %v\n", taskLabel(task), r.Slack[task])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// String returns the human-readable report as a string.
+func (r CriticalPathReport) String() string {
+	var buf bytes.Buffer
+	_ = r.Write(&buf)
+	return buf.String()
+}
@@ -0,0 +1,80 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleCriticalPath() {
+	ctx := context.Background()
+
+	criticalPath := middlewares.NewCriticalPath()
+	taskSet := taskset.NewTaskSet(
+		criticalPath.Middleware(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskB := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value.(int) + 1, nil
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskB).Value.(int) + 1, nil
+	},
+		properties.WithName("C"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// C waited for B, which waited for A. Report().String() also has the
+	// self time and wait time of each task on the path.
+	for _, t := range criticalPath.Report().Path {
+		fmt.Println(properties.Name(t.Task))
+	}
+
+	// Output:
+	// A
+	// B
+	// C
+}
+
+func TestCriticalPathReport_unnamed(t *testing.T) {
+	ctx := context.Background()
+
+	criticalPath := middlewares.NewCriticalPath()
+	taskSet := taskset.NewTaskSet(
+		criticalPath.Middleware(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	})
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value, nil
+	})
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Unnamed tasks are told apart by their position, like in graphs.
+	report := criticalPath.Report().String()
+	for _, label := range []string{"task #0:", "task #1:"} {
+		if !strings.Contains(report, label) {
+			t.Errorf("report doesn't mention %q:\n%s", label, report)
+		}
+	}
+}
@@ -2,110 +2,134 @@ package middlewares
 
 import (
 	"bytes"
-	"context"
+	"fmt"
 	"io"
-	"sync"
-
-	"github.com/bennydictor/taskset"
-	"github.com/bennydictor/taskset/properties"
+	"strings"
 )
 
 // DependGraphviz provides a middleware that records
 // all dependency declarations by all tasks, and makes this
 // information available as a graphviz source file.
+//
+// See Graph.WriteDOT for the description of the generated file.
 type DependGraphviz struct {
-	sync.Mutex
-	info map[*taskset.Task]map[*taskset.Task]struct{}
+	GraphRecorder
 }
 
 // NewDependGraphviz creates a new DependGraphviz.
 func NewDependGraphviz() *DependGraphviz {
-	return &DependGraphviz{
-		info: make(map[*taskset.Task]map[*taskset.Task]struct{}),
-	}
+	return &DependGraphviz{}
 }
 
-// Middleware provides the taskset.Middleware.
-func (d *DependGraphviz) Middleware() taskset.Middleware {
-	return taskset.Middleware{
-		Depend: d.depend,
-	}
+// Write writes the generated graphviz source file to an io.Writer.
+func (d *DependGraphviz) Write(w io.Writer) error {
+	return d.Graph().WriteDOT(w)
 }
 
-func (d *DependGraphviz) depend(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-	result := next(ctx)
-
-	d.Lock()
-	defer d.Unlock()
-
-	if _, ok := d.info[task]; !ok {
-		d.info[task] = make(map[*taskset.Task]struct{})
-	}
-	d.info[task][dependency] = struct{}{}
-
-	return result
+// String returns the generated graphviz source file as a string.
+func (d *DependGraphviz) String() string {
+	var buf bytes.Buffer
+	_ = d.Write(&buf)
+	return buf.String()
 }
 
-// Write writes the generated graphviz source file to an io.Writer.
-func (d *DependGraphviz) Write(w io.Writer) error {
-	d.Lock()
-	defer d.Unlock()
+// dotQuote returns s as a DOT quoted string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
 
+// WriteDOT writes the graph as a graphviz source file.
+//
+// Every task is a node, labeled with its name and run duration. Failed tasks are
+// colored red, successful ones green, and ones that haven't finished yet gray.
+// Lazy tasks are drawn as ellipses, and non-lazy ones as boxes. Tasks of a child
+// TaskSet created by NewSub are grouped in a cluster labeled by the parent task.
+//
+// Every dependency is an edge from the dependent task, labeled with the total time
+// the task spent waiting for it.
+func (g Graph) WriteDOT(w io.Writer) error {
 	_, err := io.WriteString(w, "digraph {\n")
 	if err != nil {
 		return err
 	}
 
-	for task, dependencies := range d.info {
-		_, err = io.WriteString(w, "    \"")
+	if err := writeDOTNodes(w, g.children(), "", "    "); err != nil {
+		return err
+	}
+
+	maxWait := g.maxWait()
+	for _, e := range g.Edges {
+		penwidth := 1.0
+		if maxWait > 0 {
+			penwidth += 4 * float64(e.Wait) / float64(maxWait)
+		}
+
+		_, err = fmt.Fprintf(w, "    %s -> %s [label=%s, penwidth=%.2f];\n",
+			e.From, e.To, dotQuote(formatDuration(e.Wait)), penwidth)
 		if err != nil {
 			return err
 		}
+	}
+
+	_, err = io.WriteString(w, "}\n")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeDOTNodes writes the nodes of the given parent, with clusters for child TaskSets.
+func writeDOTNodes(w io.Writer, children map[string][]GraphNode, parent string, indent string) error {
+	nodes := children[parent]
+
+	for _, n := range nodes {
+		label := n.Label()
+
+		color := "gray"
+		switch n.Status {
+		case TaskSucceeded:
+			color = "green"
+		case TaskFailed:
+			color = "red"
+		}
+		if n.Status != TaskRunning {
+			label += "\n" + formatDuration(n.Duration())
+		}
+
+		shape := "box"
+		if n.Lazy {
+			shape = "ellipse"
+		}
 
-		_, err = io.WriteString(w, properties.Name(task))
+		_, err := fmt.Fprintf(w, "%s%s [label=%s, shape=%s, color=%s];\n", indent, n.ID, dotQuote(label), shape, color)
 		if err != nil {
 			return err
 		}
+	}
+
+	for _, n := range nodes {
+		if len(children[n.ID]) == 0 {
+			continue
+		}
 
-		_, err = io.WriteString(w, "\" -> {")
+		_, err := fmt.Fprintf(w, "%ssubgraph cluster_%s {\n%s    label=%s;\n", indent, n.ID, indent, dotQuote(n.Label()))
 		if err != nil {
 			return err
 		}
 
-		for dependency := range dependencies {
-			_, err = io.WriteString(w, " \"")
-			if err != nil {
-				return err
-			}
-
-			_, err = io.WriteString(w, properties.Name(dependency))
-			if err != nil {
-				return err
-			}
-
-			_, err = io.WriteString(w, "\"")
-			if err != nil {
-				return err
-			}
+		if err := writeDOTNodes(w, children, n.ID, indent+"    "); err != nil {
+			return err
 		}
 
-		_, err = io.WriteString(w, " };\n")
+		_, err = fmt.Fprintf(w, "%s}\n", indent)
 		if err != nil {
 			return err
 		}
 	}
 
-	_, err = io.WriteString(w, "}\n")
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
-
-// String returns the generated graphviz source file as a string.
-func (d *DependGraphviz) String() string {
-	var buf bytes.Buffer
-	_ = d.Write(&buf)
-	return buf.String()
-}
@@ -0,0 +1,54 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleDependGraphviz() {
+	ctx := context.Background()
+
+	graphviz := middlewares.NewDependGraphviz()
+	taskSet := taskset.NewTaskSet(
+		graphviz.Middleware(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName(`say "hi"`),
+	)
+
+	taskB := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, errors.New("fail")
+	})
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend.SyncGroup(ctx, taskA, taskB)
+		return nil, nil
+	},
+		properties.WithName("C"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Timings differ between runs.
+	timings := regexp.MustCompile(`[0-9.]+[µnm]?s\b|penwidth=[0-9.]+`)
+	fmt.Print(timings.ReplaceAllString(graphviz.String(), "..."))
+
+	// Output:
+	// digraph {
+	//     task_0 [label="say \"hi\"\n...", shape=ellipse, color=green];
+	//     task_1 [label="#1\n...", shape=ellipse, color=red];
+	//     task_2 [label="C\n...", shape=box, color=green];
+	//     task_2 -> task_0 [label="...", ...];
+	//     task_2 -> task_1 [label="...", ...];
+	// }
+}
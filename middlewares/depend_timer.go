@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"sync"
+	"time"
+)
+
+// DependTimer measures the time a task spends in depend() calls, for middlewares
+// that report the task's self time, which excludes it. Overlapping depend() calls,
+// like the ones made by SyncGroup, are only counted once.
+//
+// A middleware typically creates a DependTimer for each task in Run, stores it in
+// a task property, and calls Start and Stop around next() in Depend.
+// The zero value is ready to use.
+type DependTimer struct {
+	mu          sync.Mutex
+	dependCount uint
+	start       time.Time
+	total       time.Duration
+}
+
+// Start is called when a depend() call starts.
+func (t *DependTimer) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dependCount == 0 {
+		t.start = time.Now()
+	}
+	t.dependCount++
+}
+
+// Stop is called when a depend() call, for which Start was called, finishes.
+func (t *DependTimer) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dependCount--
+	if t.dependCount == 0 {
+		t.total += time.Since(t.start)
+	}
+}
+
+// Total returns the time spent in depend() calls so far, not counting the ones still in progress.
+func (t *DependTimer) Total() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.total
+}
@@ -0,0 +1,98 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func dryRun(t *testing.T, middleware taskset.Middleware, fn taskset.RunFunc, properties ...taskset.Property) {
+	t.Helper()
+
+	taskSet := taskset.NewTaskSet(middleware)
+	taskSet.New(fn, properties...)
+	taskSet.New(fn, properties...)
+
+	if err := taskSet.DryRun(context.Background()); err != nil {
+		t.Fatalf("DryRun() = %v", err)
+	}
+}
+
+func run(middleware taskset.Middleware, fn taskset.RunFunc, properties ...taskset.Property) taskset.Result {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(middleware)
+	task := taskSet.New(fn, properties...)
+	taskSet.Start(ctx)
+	return taskSet.Result(ctx, task)
+}
+
+func succeed(context.Context, taskset.Depend) (interface{}, error) {
+	return nil, nil
+}
+
+func TestNewRateLimiter_dryRun(t *testing.T) {
+	limiter := middlewares.NewRateLimiter(map[string]*middlewares.TokenBucket{
+		"": middlewares.NewTokenBucket(0.001, 1),
+	})
+
+	dryRun(t, limiter, succeed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	taskSet := taskset.NewTaskSet(limiter)
+	task := taskSet.New(succeed)
+	taskSet.Start(ctx)
+
+	if err := taskSet.Result(ctx, task).Err; err != nil {
+		t.Errorf("result after dry run = %v, want the token to be left in the bucket", err)
+	}
+}
+
+func TestCircuitBreaker_dryRun(t *testing.T) {
+	breaker := middlewares.NewCircuitBreaker(middlewares.CircuitBreakerOptions{
+		FailureThreshold: 2,
+	})
+	fail := func(context.Context, taskset.Depend) (interface{}, error) {
+		return nil, errors.New("fail")
+	}
+
+	db := properties.WithGroup("db")
+
+	run(breaker.Middleware(), fail, db)
+	// A dry run succeeds, which would reset the failure count.
+	dryRun(t, breaker.Middleware(), fail, db)
+	run(breaker.Middleware(), fail, db)
+
+	if state := breaker.State("db"); state != middlewares.CircuitOpen {
+		t.Errorf("state = %v, want %v", state, middlewares.CircuitOpen)
+	}
+}
+
+func TestAdaptiveLimiter_dryRun(t *testing.T) {
+	limiter := middlewares.NewAdaptiveLimiter(middlewares.AdaptiveLimiterOptions{
+		InitialLimit: 1,
+	})
+
+	dryRun(t, limiter.Middleware(), succeed)
+
+	if limit := limiter.Limit(""); limit != 1 {
+		t.Errorf("limit = %v, want 1", limit)
+	}
+}
+
+func TestNewConcurrencyLimiter_dryRun(t *testing.T) {
+	var mu sync.Mutex
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Would deadlock if the dry run tried to lock mu.
+	dryRun(t, middlewares.NewConcurrencyLimiter(&mu), succeed)
+}
@@ -0,0 +1,195 @@
+package middlewares
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+)
+
+// FairLimiterOptions configures a FairLimiter.
+type FairLimiterOptions struct {
+	// Slots is the number of tasks that can run concurrently. It must be at least 1.
+	Slots int
+	// Tenant returns the tenant of a task, used by Middleware.
+	// If Tenant is nil, properties.Tenant is used.
+	Tenant func(task *taskset.Task) string
+	// Weight returns the weight of a tenant. A tenant gets slots in proportion to its weight.
+	// Weights less than 1 are treated as 1. If Weight is nil, all tenants have weight 1.
+	Weight func(tenant string) int
+}
+
+// FairLimiter provides a middleware that limits concurrency, like NewConcurrencyLimiter
+// with NewSemaphore, and shares the slots fairly between tenants, so that one tenant's
+// huge task graph can't starve everyone else. It's meant to be shared by many task sets,
+// e.g. one for each incoming request.
+//
+// The slots are given out using deficit round robin: when tasks of several tenants are waiting,
+// they take turns, and in each turn a tenant gets as many slots as its weight.
+// FairLimiter is work-conserving: when only one tenant's tasks are waiting, they get all the free slots.
+// Fairness applies to the slots as they are freed, so a tenant's running tasks are not preempted,
+// but they give up their slots while depending on other tasks, as with NewConcurrencyLimiter.
+type FairLimiter struct {
+	sync.Mutex
+	opts    FairLimiterOptions
+	free    int
+	tenants map[string]*fairTenant
+	// active are the tenants with waiting tasks, in round robin order.
+	active []*fairTenant
+}
+
+type fairTenant struct {
+	name    string
+	deficit int
+	waiters []*fairWaiter
+}
+
+type fairWaiter struct {
+	ready chan struct{}
+}
+
+// NewFairLimiter creates a new FairLimiter.
+func NewFairLimiter(opts FairLimiterOptions) *FairLimiter {
+	if opts.Slots < 1 {
+		panic("fair limiter slots must be at least 1")
+	}
+	if opts.Tenant == nil {
+		opts.Tenant = properties.Tenant
+	}
+	if opts.Weight == nil {
+		opts.Weight = func(string) int { return 1 }
+	}
+
+	return &FairLimiter{
+		opts:    opts,
+		free:    opts.Slots,
+		tenants: make(map[string]*fairTenant),
+	}
+}
+
+// Middleware provides the taskset.Middleware, which takes each task's tenant from opts.Tenant.
+func (f *FairLimiter) Middleware() taskset.Middleware {
+	return newConcurrencyLimiter(func(task *taskset.Task) (ContextLocker, func(taskset.Result)) {
+		return fairSlot{f, f.opts.Tenant(task)}, func(taskset.Result) {}
+	})
+}
+
+// TenantMiddleware provides the taskset.Middleware, which runs all tasks on behalf of the given tenant.
+// Pass it to NewTaskSet to tag the whole task set with a tenant:
+//
+//	taskSet := taskset.NewTaskSet(limiter.TenantMiddleware(request.Tenant))
+func (f *FairLimiter) TenantMiddleware(tenant string) taskset.Middleware {
+	return newConcurrencyLimiter(func(task *taskset.Task) (ContextLocker, func(taskset.Result)) {
+		return fairSlot{f, tenant}, func(taskset.Result) {}
+	})
+}
+
+func (f *FairLimiter) acquire(ctx context.Context, tenant string) error {
+	f.Lock()
+	if f.free > 0 && len(f.active) == 0 {
+		f.free--
+		f.Unlock()
+		return nil
+	}
+
+	t, ok := f.tenants[tenant]
+	if !ok {
+		t = &fairTenant{name: tenant}
+		f.tenants[tenant] = t
+		f.active = append(f.active, t)
+	}
+	w := &fairWaiter{ready: make(chan struct{})}
+	t.waiters = append(t.waiters, w)
+	f.dispatch()
+	f.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	select {
+	case <-w.ready:
+		// The slot was given out concurrently with cancellation, give it back.
+		f.free++
+		f.dispatch()
+	default:
+		for i, other := range t.waiters {
+			if other == w {
+				t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+				break
+			}
+		}
+		if len(t.waiters) == 0 {
+			f.deactivate(t)
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (f *FairLimiter) release() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.free++
+	f.dispatch()
+}
+
+// dispatch gives out the free slots to the waiting tasks.
+func (f *FairLimiter) dispatch() {
+	for f.free > 0 && len(f.active) > 0 {
+		t := f.active[0]
+		if t.deficit <= 0 {
+			weight := f.opts.Weight(t.name)
+			if weight < 1 {
+				weight = 1
+			}
+			t.deficit += weight
+		}
+
+		w := t.waiters[0]
+		t.waiters = t.waiters[1:]
+		t.deficit--
+		f.free--
+		close(w.ready)
+
+		if len(t.waiters) == 0 {
+			f.deactivate(t)
+		} else if t.deficit <= 0 {
+			f.active = append(f.active[1:], t)
+		}
+	}
+}
+
+func (f *FairLimiter) deactivate(t *fairTenant) {
+	for i, other := range f.active {
+		if other == t {
+			f.active = append(f.active[:i], f.active[i+1:]...)
+			break
+		}
+	}
+	delete(f.tenants, t.name)
+}
+
+type fairSlot struct {
+	limiter *FairLimiter
+	tenant  string
+}
+
+func (s fairSlot) Lock() {
+	_ = s.limiter.acquire(context.Background(), s.tenant)
+}
+
+func (s fairSlot) LockContext(ctx context.Context) error {
+	return s.limiter.acquire(ctx, s.tenant)
+}
+
+func (s fairSlot) Unlock() {
+	s.limiter.release()
+}
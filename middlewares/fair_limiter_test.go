@@ -0,0 +1,94 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleNewFairLimiter() {
+	ctx := context.Background()
+
+	// The limiter is shared by all requests.
+	limiter := middlewares.NewFairLimiter(middlewares.FairLimiterOptions{
+		Slots: 2,
+	})
+
+	request := func(tenant string, tasks int) *taskset.TaskSet {
+		// Each request runs its own task set, tagged with the request's tenant.
+		taskSet := taskset.NewTaskSet(limiter.TenantMiddleware(tenant))
+		for i := 0; i < tasks; i++ {
+			taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+				fmt.Println(tenant)
+				return nil, nil
+			})
+		}
+		taskSet.Start(ctx)
+		return taskSet
+	}
+
+	// While both requests wait for slots, the tenants take turns,
+	// so the small request isn't stuck behind the whole big one.
+	big := request("big", 3)
+	small := request("small", 1)
+
+	big.Wait(ctx)
+	small.Wait(ctx)
+
+	// Unordered output:
+	// big
+	// big
+	// big
+	// small
+}
+
+func ExampleFairLimiter_Middleware() {
+	ctx := context.Background()
+
+	limiter := middlewares.NewFairLimiter(middlewares.FairLimiterOptions{
+		Slots: 1,
+		// While both tenants wait for slots, premium tasks get twice as many turns.
+		Weight: func(tenant string) int {
+			if tenant == "premium" {
+				return 2
+			}
+			return 1
+		},
+	})
+
+	taskSet := taskset.NewTaskSet(limiter.Middleware())
+	for _, tenant := range []string{"free", "premium", "premium"} {
+		tenant := tenant
+		taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			fmt.Println(tenant)
+			return nil, nil
+		},
+			properties.WithTenant(tenant),
+		)
+	}
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Unordered output:
+	// free
+	// premium
+	// premium
+}
+
+func TestNewFairLimiter_invalid(t *testing.T) {
+	for _, slots := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewFairLimiter(Slots: %v) didn't panic", slots)
+				}
+			}()
+			middlewares.NewFairLimiter(middlewares.FairLimiterOptions{Slots: slots})
+		}()
+	}
+}
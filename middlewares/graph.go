@@ -0,0 +1,489 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+)
+
+// TaskStatus is the status of a task in a recorded Graph.
+type TaskStatus string
+
+const (
+	// TaskRunning is the status of a task that hasn't finished yet, or hasn't started.
+	TaskRunning = TaskStatus("running")
+	// TaskSucceeded is the status of a task that returned a successful Result.
+	TaskSucceeded = TaskStatus("succeeded")
+	// TaskFailed is the status of a task that returned a failed Result.
+	TaskFailed = TaskStatus("failed")
+)
+
+// Graph is a recorded execution of task sets, made by GraphRecorder.
+// Graph can be rendered in several formats, see WriteDOT, WriteMermaid and WriteJSON.
+type Graph struct {
+	// Nodes are the recorded tasks, sorted by their ID.
+	Nodes []GraphNode
+	// Edges are the recorded dependencies, sorted by their From and To nodes.
+	Edges []GraphEdge
+}
+
+// GraphNode is a task in a recorded Graph.
+type GraphNode struct {
+	Task *taskset.Task
+	// ID identifies the task in the graph, and is the same between runs of the same task set.
+	// It's made of the task's position in the tree of task sets related by NewSub, e.g. task_1_0.
+	// If the GraphRecorder was used by several top-level TaskSets, the IDs of the tasks
+	// of all but the first of them, ordered by their start, are prefixed with the TaskSet's
+	// number, e.g. set1_task_1_0.
+	ID string
+	// Name is properties.Name of the task.
+	Name string
+	// Parent is the ID of the task that ran this task's TaskSet, see taskset.Task.Parent.
+	// It's empty for tasks of the top-level TaskSet.
+	Parent string
+	Lazy   bool
+	Status TaskStatus
+	// Err is the task's error, if the task failed.
+	Err error
+	// Start and End are the times the task started and finished running.
+	// They are zero if the task hasn't started or finished yet.
+	Start, End time.Time
+	// Properties is a copy of the task's properties at the time the Graph was made.
+	Properties map[interface{}]interface{}
+}
+
+// Label returns the node's name, or "#" followed by its position in the task set if it has no name.
+func (n GraphNode) Label() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	path := n.ID
+	if i := strings.Index(path, "task_"); i >= 0 {
+		path = path[i+len("task_"):]
+	}
+	return "#" + strings.ReplaceAll(path, "_", ".")
+}
+
+// Duration returns the time the task ran for, or zero if it hasn't finished.
+func (n GraphNode) Duration() time.Duration {
+	if n.Start.IsZero() || n.End.IsZero() {
+		return 0
+	}
+	return n.End.Sub(n.Start)
+}
+
+// GraphEdge is a dependency in a recorded Graph.
+type GraphEdge struct {
+	// From is the ID of the dependent task, and To is the ID of its dependency.
+	From, To string
+	// Wait is the total time the dependent task spent in depend() waiting for the dependency.
+	Wait time.Duration
+}
+
+// GraphRecorder provides a middleware that records all tasks and dependency
+// declarations, and makes them available as a Graph. The zero value is ready to use.
+type GraphRecorder struct {
+	sync.Mutex
+	nodes map[*taskset.Task]*recordedNode
+	edges map[*taskset.Task]map[*taskset.Task]time.Duration
+	// sets are the top-level TaskSets of the recorded tasks, in the order they were first seen.
+	sets []*taskset.TaskSet
+}
+
+type recordedNode struct {
+	start, end time.Time
+	err        error
+}
+
+// NewGraphRecorder creates a new GraphRecorder.
+func NewGraphRecorder() *GraphRecorder {
+	return &GraphRecorder{}
+}
+
+// Middleware provides the taskset.Middleware.
+func (g *GraphRecorder) Middleware() taskset.Middleware {
+	return taskset.Middleware{
+		Run:    g.run,
+		Depend: g.depend,
+	}
+}
+
+func (g *GraphRecorder) node(task *taskset.Task) *recordedNode {
+	if g.nodes == nil {
+		g.nodes = make(map[*taskset.Task]*recordedNode)
+	}
+
+	n, ok := g.nodes[task]
+	if !ok {
+		n = &recordedNode{}
+		g.nodes[task] = n
+
+		set := topLevelSet(task)
+		if !containsSet(g.sets, set) {
+			g.sets = append(g.sets, set)
+		}
+	}
+	return n
+}
+
+func containsSet(sets []*taskset.TaskSet, set *taskset.TaskSet) bool {
+	for _, s := range sets {
+		if s == set {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelSet returns the TaskSet at the root of the tree of task sets the task belongs to.
+func topLevelSet(task *taskset.Task) *taskset.TaskSet {
+	for task.Parent() != nil {
+		task = task.Parent()
+	}
+	return task.TaskSet()
+}
+
+// setNumbers numbers the recorded top-level TaskSets by the earliest start of their tasks.
+// Sets whose tasks haven't started come last, in the order they were first seen.
+func (g *GraphRecorder) setNumbers() map[*taskset.TaskSet]int {
+	starts := make(map[*taskset.TaskSet]time.Time, len(g.sets))
+	for task, n := range g.nodes {
+		set := topLevelSet(task)
+		if start := starts[set]; !n.start.IsZero() && (start.IsZero() || n.start.Before(start)) {
+			starts[set] = n.start
+		}
+	}
+
+	sets := append([]*taskset.TaskSet(nil), g.sets...)
+	sort.SliceStable(sets, func(i, j int) bool {
+		a, b := starts[sets[i]], starts[sets[j]]
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+
+	numbers := make(map[*taskset.TaskSet]int, len(sets))
+	for i, set := range sets {
+		numbers[set] = i
+	}
+	return numbers
+}
+
+func (g *GraphRecorder) run(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	g.Lock()
+	g.node(task).start = time.Now()
+	g.Unlock()
+
+	result := next(ctx)
+
+	g.Lock()
+	defer g.Unlock()
+
+	n := g.node(task)
+	n.end = time.Now()
+	n.err = result.Err
+
+	return result
+}
+
+func (g *GraphRecorder) depend(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+	start := time.Now()
+	result := next(ctx)
+	wait := time.Since(start)
+
+	g.Lock()
+	defer g.Unlock()
+
+	g.node(task)
+	g.node(dependency)
+	if g.edges == nil {
+		g.edges = make(map[*taskset.Task]map[*taskset.Task]time.Duration)
+	}
+	if _, ok := g.edges[task]; !ok {
+		g.edges[task] = make(map[*taskset.Task]time.Duration)
+	}
+	g.edges[task][dependency] += wait
+
+	return result
+}
+
+// Graph returns the graph recorded so far. The returned Graph only depends on
+// the recorded tasks, dependencies and timings, and not on the order they were recorded in.
+func (g *GraphRecorder) Graph() Graph {
+	g.Lock()
+	defer g.Unlock()
+
+	sets := g.setNumbers()
+	id := func(task *taskset.Task) string {
+		return taskID(sets[topLevelSet(task)], task)
+	}
+	sortTasks := func(tasks []*taskset.Task) {
+		sort.Slice(tasks, func(i, j int) bool {
+			a, b := sets[topLevelSet(tasks[i])], sets[topLevelSet(tasks[j])]
+			if a != b {
+				return a < b
+			}
+			return taskPathLess(taskPath(tasks[i]), taskPath(tasks[j]))
+		})
+	}
+
+	tasks := make([]*taskset.Task, 0, len(g.nodes))
+	for task := range g.nodes {
+		tasks = append(tasks, task)
+	}
+	sortTasks(tasks)
+
+	var graph Graph
+	for _, task := range tasks {
+		n := g.nodes[task]
+
+		node := GraphNode{
+			Task:       task,
+			ID:         id(task),
+			Name:       properties.Name(task),
+			Lazy:       task.Lazy(),
+			Status:     TaskRunning,
+			Err:        n.err,
+			Start:      n.start,
+			End:        n.end,
+			Properties: task.Properties(),
+		}
+		if task.Parent() != nil {
+			node.Parent = id(task.Parent())
+		}
+		if !n.end.IsZero() {
+			if n.err != nil {
+				node.Status = TaskFailed
+			} else {
+				node.Status = TaskSucceeded
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		dependencies := make([]*taskset.Task, 0, len(g.edges[task]))
+		for dependency := range g.edges[task] {
+			dependencies = append(dependencies, dependency)
+		}
+		sortTasks(dependencies)
+
+		for _, dependency := range dependencies {
+			graph.Edges = append(graph.Edges, GraphEdge{
+				From: id(task),
+				To:   id(dependency),
+				Wait: g.edges[task][dependency],
+			})
+		}
+	}
+
+	return graph
+}
+
+// taskPath is the position of the task in the tree of task sets.
+func taskPath(task *taskset.Task) []int {
+	if task == nil {
+		return nil
+	}
+	return append(taskPath(task.Parent()), task.Index())
+}
+
+func taskPathLess(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// taskID returns an identifier of the task, unique among the tasks of all TaskSets
+// that are related by NewSub. Tasks of different top-level TaskSets are told apart by set,
+// the number of the task's top-level TaskSet, see GraphNode.ID.
+func taskID(set int, task *taskset.Task) string {
+	var id strings.Builder
+	if set != 0 {
+		id.WriteString("set")
+		id.WriteString(strconv.Itoa(set))
+		id.WriteString("_")
+	}
+	id.WriteString("task")
+	for _, i := range taskPath(task) {
+		id.WriteString("_")
+		id.WriteString(strconv.Itoa(i))
+	}
+	return id.String()
+}
+
+// taskLabel returns properties.Name of the task, or "#" followed by its position in
+// the tree of task sets if it has no name, like GraphNode.Label.
+func taskLabel(task *taskset.Task) string {
+	if name := properties.Name(task); name != "" {
+		return name
+	}
+
+	var label strings.Builder
+	label.WriteString("#")
+	for i, index := range taskPath(task) {
+		if i > 0 {
+			label.WriteString(".")
+		}
+		label.WriteString(strconv.Itoa(index))
+	}
+	return label.String()
+}
+
+// children groups the nodes by their Parent.
+func (g Graph) children() map[string][]GraphNode {
+	children := make(map[string][]GraphNode)
+	for _, n := range g.Nodes {
+		children[n.Parent] = append(children[n.Parent], n)
+	}
+	return children
+}
+
+func (g Graph) maxWait() (maxWait time.Duration) {
+	for _, e := range g.Edges {
+		if e.Wait > maxWait {
+			maxWait = e.Wait
+		}
+	}
+	return
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
+
+type jsonGraph struct {
+	Nodes []jsonNode `json:"nodes"`
+	Edges []jsonEdge `json:"edges"`
+}
+
+type jsonNode struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Parent          string            `json:"parent,omitempty"`
+	Lazy            bool              `json:"lazy"`
+	Status          TaskStatus        `json:"status"`
+	Error           string            `json:"error,omitempty"`
+	Start           *time.Time        `json:"start,omitempty"`
+	End             *time.Time        `json:"end,omitempty"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Properties      map[string]string `json:"properties,omitempty"`
+}
+
+type jsonEdge struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// WriteJSON writes the graph as a JSON object of the following form:
+//
+//	{
+//	  "nodes": [
+//	    {
+//	      "id": "task_1_0",              // GraphNode.ID
+//	      "name": "fetch",               // properties.Name, may be empty
+//	      "parent": "task_1",            // omitted for tasks of the top-level TaskSet
+//	      "lazy": true,
+//	      "status": "failed",            // "running", "succeeded" or "failed"
+//	      "error": "timeout",            // omitted unless the task failed
+//	      "start": "2021-10-01T12:00:00.000000001Z", // omitted if the task hasn't started
+//	      "end": "2021-10-01T12:00:01.5Z",           // omitted if the task hasn't finished
+//	      "duration_seconds": 1.5,       // zero if the task hasn't finished
+//	      "properties": {"properties.nameProperty": "fetch"}
+//	    }
+//	  ],
+//	  "edges": [
+//	    {"from": "task_1", "to": "task_1_0", "wait_seconds": 1.5}
+//	  ]
+//	}
+//
+// Properties are written as returned by GraphNode.PropertyStrings.
+func (g Graph) WriteJSON(w io.Writer) error {
+	out := jsonGraph{
+		Nodes: make([]jsonNode, 0, len(g.Nodes)),
+		Edges: make([]jsonEdge, 0, len(g.Edges)),
+	}
+
+	for _, n := range g.Nodes {
+		node := jsonNode{
+			ID:              n.ID,
+			Name:            n.Name,
+			Parent:          n.Parent,
+			Lazy:            n.Lazy,
+			Status:          n.Status,
+			DurationSeconds: n.Duration().Seconds(),
+			Properties:      n.PropertyStrings(),
+		}
+		if n.Err != nil {
+			node.Error = n.Err.Error()
+		}
+		if !n.Start.IsZero() {
+			start := n.Start
+			node.Start = &start
+		}
+		if !n.End.IsZero() {
+			end := n.End
+			node.End = &end
+		}
+		out.Nodes = append(out.Nodes, node)
+	}
+
+	for _, e := range g.Edges {
+		out.Edges = append(out.Edges, jsonEdge{
+			From:        e.From,
+			To:          e.To,
+			WaitSeconds: e.Wait.Seconds(),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// PropertyStrings returns the node's properties as strings, keyed by the Go type of
+// the property key. Only properties with values of basic types, or implementing
+// fmt.Stringer or error, are returned.
+func (n GraphNode) PropertyStrings() map[string]string {
+	out := make(map[string]string)
+	for key, value := range n.Properties {
+		switch v := value.(type) {
+		case fmt.Stringer:
+			out[fmt.Sprintf("%T", key)] = v.String()
+		case error:
+			out[fmt.Sprintf("%T", key)] = v.Error()
+		default:
+			switch reflect.ValueOf(value).Kind() {
+			case reflect.String, reflect.Bool,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				out[fmt.Sprintf("%T", key)] = fmt.Sprint(value)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
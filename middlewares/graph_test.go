@@ -0,0 +1,156 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func recordGraph() middlewares.Graph {
+	ctx := context.Background()
+
+	recorder := middlewares.NewGraphRecorder()
+	taskSet := taskset.NewTaskSet(
+		recorder.Middleware(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, errors.New("fail")
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.NewSub(func(child *taskset.TaskSet) *taskset.Task {
+		return child.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, depend(ctx, taskA).Err
+		},
+			properties.WithName("B"),
+		)
+	},
+		properties.WithName("C"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	return recorder.Graph()
+}
+
+func ExampleGraph_WriteMermaid() {
+	var out strings.Builder
+	if err := recordGraph().WriteMermaid(&out); err != nil {
+		panic(err)
+	}
+
+	// Timings differ between runs.
+	timings := regexp.MustCompile(`[0-9.]+[µnm]?s\b`)
+	fmt.Print(timings.ReplaceAllString(out.String(), "..."))
+
+	// Output:
+	// flowchart TD
+	//     task_0("A<br/>...")
+	//     task_1["C<br/>..."]
+	//     subgraph cluster_task_1 ["C"]
+	//         task_1_0["B<br/>..."]
+	//     end
+	//     task_1 -->|"..."| task_1_0
+	//     task_1_0 -->|"..."| task_0
+	//     classDef running stroke:gray
+	//     classDef succeeded stroke:green
+	//     classDef failed stroke:red
+	//     class task_0 failed
+	//     class task_1 failed
+	//     class task_1_0 failed
+}
+
+func ExampleGraph_WriteJSON() {
+	var out strings.Builder
+	if err := recordGraph().WriteJSON(&out); err != nil {
+		panic(err)
+	}
+
+	var graph struct {
+		Nodes []struct {
+			ID         string
+			Parent     string
+			Status     string
+			Error      string
+			Properties map[string]string
+		}
+		Edges []struct {
+			From, To string
+		}
+	}
+	if err := json.Unmarshal([]byte(out.String()), &graph); err != nil {
+		panic(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, n := range graph.Nodes {
+		_ = encoder.Encode(n)
+	}
+	for _, e := range graph.Edges {
+		_ = encoder.Encode(e)
+	}
+
+	// Output:
+	// {"ID":"task_0","Parent":"","Status":"failed","Error":"fail","Properties":{"properties.nameProperty":"A"}}
+	// {"ID":"task_1","Parent":"","Status":"failed","Error":"fail","Properties":{"properties.nameProperty":"C"}}
+	// {"ID":"task_1_0","Parent":"task_1","Status":"failed","Error":"fail","Properties":{"properties.nameProperty":"B"}}
+	// {"From":"task_1","To":"task_1_0"}
+	// {"From":"task_1_0","To":"task_0"}
+}
+
+func ExampleGraphRecorder_shared() {
+	ctx := context.Background()
+
+	// Each request runs its own task set, sharing the recorder.
+	recorder := middlewares.NewGraphRecorder()
+	request := func(name string) {
+		taskSet := taskset.NewTaskSet(
+			recorder.Middleware(),
+		)
+
+		taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, nil
+		},
+			properties.WithName(name+" A"),
+		)
+
+		taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, depend(ctx, taskA).Err
+		},
+			properties.WithName(name+" B"),
+		)
+
+		taskSet.Start(ctx)
+		taskSet.Wait(ctx)
+	}
+
+	request("first")
+	request("second")
+
+	graph := recorder.Graph()
+	for _, n := range graph.Nodes {
+		fmt.Println(n.ID, n.Name)
+	}
+	for _, e := range graph.Edges {
+		fmt.Println(e.From, "->", e.To)
+	}
+
+	// Output:
+	// task_0 first A
+	// task_1 first B
+	// set1_task_0 second A
+	// set1_task_1 second B
+	// task_1 -> task_0
+	// set1_task_1 -> set1_task_0
+}
@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"sync"
+
+	"github.com/bennydictor/taskset"
+	"golang.org/x/sync/semaphore"
+)
+
+// KeyedLimit limits the number of tasks with the same key that run concurrently.
+type KeyedLimit struct {
+	// Key returns the key of a task, e.g. properties.Group.
+	// Tasks with an empty key are not limited.
+	Key func(task *taskset.Task) string
+	// Limit is the maximum total weight of concurrently running tasks with the same key,
+	// see WithWeight.
+	Limit int64
+}
+
+type keyedSemaphores struct {
+	sync.Mutex
+	KeyedLimit
+	semaphores map[string]*keyedSemaphore
+}
+
+type keyedSemaphore struct {
+	*semaphore.Weighted
+	users int
+}
+
+// get returns the semaphore for key, and a function to call once it's no longer used.
+// Unused semaphores are deleted, so that keys that come and go don't leak memory.
+func (k *keyedSemaphores) get(key string) (*semaphore.Weighted, func()) {
+	k.Lock()
+	defer k.Unlock()
+
+	s, ok := k.semaphores[key]
+	if !ok {
+		s = &keyedSemaphore{Weighted: semaphore.NewWeighted(k.Limit)}
+		k.semaphores[key] = s
+	}
+	s.users++
+
+	return s.Weighted, func() {
+		k.Lock()
+		defer k.Unlock()
+
+		s.users--
+		if s.users == 0 {
+			delete(k.semaphores, key)
+		}
+	}
+}
+
+// NewKeyedConcurrencyLimiter creates a middleware used to limit the concurrency of tasks
+// with the same key, e.g. "at most 4 tasks per group" and "at most 1 task per tenant".
+// A task is limited by every limit it has a key for, and locks them in the order they're given.
+// The read and write locks provided using WithReadLock and WithWriteLock are locked after them.
+//
+// Keyed concurrency limiter works like NewConcurrencyLimiter with a semaphore for each key:
+// it releases the task's slots while the task is in the process of depending on another task,
+// and honours the task's context when waiting for them.
+// The same middleware can be used by many task sets to limit them together.
+func NewKeyedConcurrencyLimiter(limits ...KeyedLimit) taskset.Middleware {
+	keyed := make([]*keyedSemaphores, len(limits))
+	for i, limit := range limits {
+		keyed[i] = &keyedSemaphores{
+			KeyedLimit: limit,
+			semaphores: make(map[string]*keyedSemaphore),
+		}
+	}
+
+	return newConcurrencyLimiter(func(task *taskset.Task) (ContextLocker, func(taskset.Result)) {
+		var locks multiLocker
+		var releases []func()
+
+		for _, k := range keyed {
+			key := k.Key(task)
+			if key == "" {
+				continue
+			}
+
+			s, release := k.get(key)
+			locks = append(locks, weighted(semaphoreLocker{s, 1}, task))
+			releases = append(releases, release)
+		}
+
+		return append(locks, rwLocks(task)...), func(taskset.Result) {
+			for _, release := range releases {
+				release()
+			}
+		}
+	})
+}
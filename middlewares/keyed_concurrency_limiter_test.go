@@ -0,0 +1,136 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+type tenantProperty struct{}
+
+func withTenant(tenant string) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(tenantProperty{}, func(_ interface{}) interface{} {
+			return tenant
+		})
+	}
+}
+
+func tenant(task *taskset.Task) string {
+	tenant, _ := task.Property(tenantProperty{}).(string)
+	return tenant
+}
+
+func ExampleNewKeyedConcurrencyLimiter() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewKeyedConcurrencyLimiter(
+			middlewares.KeyedLimit{Key: properties.Group, Limit: 2},
+			middlewares.KeyedLimit{Key: tenant, Limit: 1},
+		),
+	)
+
+	sleep := func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	}
+
+	// At most 2 "db" tasks run at once, so they take two rounds.
+	for i := 0; i < 4; i++ {
+		taskSet.New(sleep, properties.WithGroup("db"))
+	}
+
+	// At most 1 task of tenant "t" runs at once, so they take two rounds,
+	// at the same time as the "db" tasks.
+	taskSet.New(sleep, withTenant("t"))
+	taskSet.New(sleep, withTenant("t"))
+
+	start := time.Now()
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+	totalTime := time.Since(start)
+
+	fmt.Printf("total time: %.1fs\n", totalTime.Seconds())
+	// Output: total time: 0.4s
+}
+
+func ExampleWithWeight() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewConcurrencyLimiter(middlewares.NewSemaphore(2)),
+	)
+
+	var running int32
+	sleep := func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	}
+
+	// The heavy task takes both slots, so it runs alone.
+	heavy := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		time.Sleep(100 * time.Millisecond)
+		return atomic.LoadInt32(&running), nil
+	},
+		middlewares.WithWeight(2),
+	)
+	taskSet.New(sleep)
+	taskSet.New(sleep)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	fmt.Println("tasks running with the heavy task:", taskSet.Result(ctx, heavy).Value)
+	// Output: tasks running with the heavy task: 1
+}
+
+// waitOrFail waits for the task set, and fails the test if it doesn't finish in time,
+// e.g. because stacked limiters deadlock.
+func waitOrFail(t *testing.T, taskSet *taskset.TaskSet) {
+	t.Helper()
+
+	select {
+	case <-taskSet.WaitC():
+	case <-time.After(5 * time.Second):
+		t.Fatal("task set didn't finish")
+	}
+}
+
+func TestNewKeyedConcurrencyLimiter_stacked(t *testing.T) {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewConcurrencyLimiter(middlewares.NewSemaphore(1)),
+		middlewares.NewKeyedConcurrencyLimiter(middlewares.KeyedLimit{Key: properties.Group, Limit: 1}),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithGroup("db"),
+	)
+
+	taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value.(int) + 1, nil
+	},
+		properties.WithGroup("db"),
+	)
+
+	taskSet.Start(ctx)
+	waitOrFail(t, taskSet)
+
+	if result := taskSet.Result(ctx, taskB); result.Value != 2 || result.Err != nil {
+		t.Errorf("B result = %v, %v, want 2, <nil>", result.Value, result.Err)
+	}
+}
@@ -2,8 +2,12 @@ package middlewares
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"sync/atomic"
+	"time"
 
 	"github.com/bennydictor/taskset"
 	"github.com/bennydictor/taskset/properties"
@@ -11,8 +15,78 @@ import (
 
 // Logger is a basic logging middleware. It will log using log.Println
 // when each task is started and finished.
-var Logger = taskset.Middleware{
-	Run: run,
+var Logger = NewLogger(LoggerOptions{})
+
+// LogFormat is a format of the records written by NewLogger.
+type LogFormat int
+
+const (
+	// LogText formats records as human-readable lines, e.g. "task A finished successfully".
+	LogText LogFormat = iota
+	// LogJSON formats records as JSON objects, one per line.
+	LogJSON
+)
+
+// LoggerOptions configures a logging middleware created by NewLogger.
+type LoggerOptions struct {
+	// Logger is used to write records. If both Logger and Writer are nil,
+	// the standard logger of the log package is used.
+	// With LogJSON, Logger should have no prefix and no flags, so that its output is valid JSON lines.
+	Logger *log.Logger
+	// Writer is used to write records if Logger is nil.
+	// With LogText, each line is prefixed with the date and time, as log.LstdFlags does.
+	// With LogJSON, each record has a "time" field instead.
+	Writer io.Writer
+	// Format is the format of the records.
+	Format LogFormat
+
+	// LogDepends enables logging when each depend() call starts and finishes.
+	LogDepends bool
+	// Durations enables logging each task's elapsed time and self time,
+	// which excludes the time the task spends in depend() calls,
+	// and the time each depend() call waited for.
+	Durations bool
+
+	// FailuresOnly disables logging task starts and successful task finishes,
+	// so that only failed tasks are logged. Successful tasks whose self time is
+	// at least SlowThreshold are still logged, unless SlowThreshold is zero.
+	FailuresOnly bool
+	// SlowThreshold is the self time after which a successful task is logged even if FailuresOnly is set.
+	SlowThreshold time.Duration
+}
+
+type logRecord struct {
+	Time       string `json:"time,omitempty"`
+	Task       string `json:"task"`
+	Dependency string `json:"dependency,omitempty"`
+	Event      string `json:"event"`
+	Elapsed    string `json:"elapsed,omitempty"`
+	Self       string `json:"self,omitempty"`
+	Wait       string `json:"wait,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r logRecord) text(task, dependency *taskset.Task) string {
+	durations := ""
+	if r.Elapsed != "" {
+		durations = fmt.Sprintf(" in %s (self %s)", r.Elapsed, r.Self)
+	}
+
+	switch r.Event {
+	case "started":
+		return fmt.Sprintf("%s starting", taskName(task))
+	case "finished":
+		return fmt.Sprintf("%s finished successfully%s", taskName(task), durations)
+	case "failed":
+		return fmt.Sprintf("%s failed%s: %s", taskName(task), durations, r.Error)
+	case "depend started":
+		return fmt.Sprintf("%s waiting for %s", taskName(task), taskName(dependency))
+	default:
+		if r.Wait != "" {
+			durations = fmt.Sprintf(" in %s", r.Wait)
+		}
+		return fmt.Sprintf("%s done waiting for %s%s", taskName(task), taskName(dependency), durations)
+	}
 }
 
 func taskName(task *taskset.Task) string {
@@ -24,16 +98,110 @@ func taskName(task *taskset.Task) string {
 	}
 }
 
-func run(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-	log.Println(taskName(task), "starting")
+// dependTimerProperty is the key of a logger's DependTimer of a task.
+// Each logger has its own id, so that stacked loggers don't overwrite each other's timers.
+type dependTimerProperty struct {
+	id uint64
+}
 
-	result := next(ctx)
+var lastLoggerID uint64
 
-	if result.Err != nil {
-		log.Println(taskName(task), "failed:", result.Err.Error())
-	} else {
-		log.Println(taskName(task), "finished successfully")
+// NewLogger creates a logging middleware configured by opts.
+// It logs when each task is started and finished, and optionally when each depend() call
+// starts and finishes.
+func NewLogger(opts LoggerOptions) taskset.Middleware {
+	logger := opts.Logger
+	addTime := false
+	if logger == nil && opts.Writer != nil {
+		if opts.Format == LogJSON {
+			logger = log.New(opts.Writer, "", 0)
+			addTime = true
+		} else {
+			logger = log.New(opts.Writer, "", log.LstdFlags)
+		}
 	}
 
-	return result
+	timerKey := dependTimerProperty{atomic.AddUint64(&lastLoggerID, 1)}
+
+	write := func(r logRecord, task, dependency *taskset.Task) {
+		var line string
+		if opts.Format == LogJSON {
+			if addTime {
+				r.Time = time.Now().Format(time.RFC3339Nano)
+			}
+			r.Task = properties.Name(task)
+			if dependency != nil {
+				r.Dependency = properties.Name(dependency)
+			}
+			data, _ := json.Marshal(r)
+			line = string(data)
+		} else {
+			line = r.text(task, dependency)
+		}
+
+		if logger != nil {
+			_ = logger.Output(3, line)
+		} else {
+			_ = log.Output(3, line)
+		}
+	}
+
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if !opts.FailuresOnly {
+				write(logRecord{Event: "started"}, task, nil)
+			}
+
+			start := time.Now()
+			timer := &DependTimer{}
+			task.ModifyProperty(timerKey, func(_ interface{}) interface{} {
+				return timer
+			})
+
+			result := next(ctx)
+
+			elapsed := time.Since(start)
+			self := elapsed - timer.Total()
+
+			if result.Err == nil && opts.FailuresOnly && (opts.SlowThreshold <= 0 || self < opts.SlowThreshold) {
+				return result
+			}
+
+			r := logRecord{Event: "finished"}
+			if result.Err != nil {
+				r.Event = "failed"
+				r.Error = result.Err.Error()
+			}
+			if opts.Durations {
+				r.Elapsed = elapsed.String()
+				r.Self = self.String()
+			}
+			write(r, task, nil)
+
+			return result
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if timer, ok := task.Property(timerKey).(*DependTimer); ok {
+				timer.Start()
+				defer timer.Stop()
+			}
+
+			if opts.LogDepends {
+				write(logRecord{Event: "depend started"}, task, dependency)
+			}
+
+			start := time.Now()
+			result := next(ctx)
+
+			if opts.LogDepends {
+				r := logRecord{Event: "depend finished"}
+				if opts.Durations {
+					r.Wait = time.Since(start).String()
+				}
+				write(r, task, dependency)
+			}
+
+			return result
+		},
+	}
 }
@@ -0,0 +1,90 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleNewLogger() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewLogger(middlewares.LoggerOptions{
+			Logger:     log.New(os.Stdout, "", 0),
+			LogDepends: true,
+		}),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, errors.New("oops")
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend(ctx, taskA)
+		return nil, nil
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Output:
+	// task B starting
+	// task B waiting for task A
+	// task A starting
+	// task A failed: oops
+	// task B done waiting for task A
+	// task B finished successfully
+}
+
+func ExampleNewLogger_failuresOnly() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewLogger(middlewares.LoggerOptions{
+			Logger:        log.New(os.Stdout, "", 0),
+			Format:        middlewares.LogJSON,
+			FailuresOnly:  true,
+			SlowThreshold: 100 * time.Millisecond,
+		}),
+	)
+
+	taskA := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, errors.New("oops")
+	},
+		properties.WithName("A"),
+	)
+
+	taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		// C waits for A and B, but it's not slow itself.
+		depend(ctx, taskA)
+		depend(ctx, taskB)
+		return nil, nil
+	},
+		properties.WithName("C"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Output:
+	// {"task":"A","event":"failed","error":"oops"}
+	// {"task":"B","event":"finished"}
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mermaidQuote returns s as a Mermaid quoted string.
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + s + `"`
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+//
+// The flowchart has the same contents as the one written by WriteDOT: lazy tasks are
+// drawn with rounded corners, tasks are styled by their status using the classes
+// "running", "succeeded" and "failed", and tasks of child TaskSets are grouped in subgraphs.
+func (g Graph) WriteMermaid(w io.Writer) error {
+	_, err := io.WriteString(w, "flowchart TD\n")
+	if err != nil {
+		return err
+	}
+
+	if err := writeMermaidNodes(w, g.children(), "", "    "); err != nil {
+		return err
+	}
+
+	for _, e := range g.Edges {
+		_, err = fmt.Fprintf(w, "    %s -->|%s| %s\n", e.From, mermaidQuote(formatDuration(e.Wait)), e.To)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "    classDef running stroke:gray\n"+
+		"    classDef succeeded stroke:green\n"+
+		"    classDef failed stroke:red\n")
+	if err != nil {
+		return err
+	}
+
+	for _, n := range g.Nodes {
+		_, err = fmt.Fprintf(w, "    class %s %s\n", n.ID, n.Status)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeMermaidNodes writes the nodes of the given parent, with subgraphs for child TaskSets.
+func writeMermaidNodes(w io.Writer, children map[string][]GraphNode, parent string, indent string) error {
+	nodes := children[parent]
+
+	for _, n := range nodes {
+		label := n.Label()
+		if n.Status != TaskRunning {
+			label += "\n" + formatDuration(n.Duration())
+		}
+
+		openShape, closeShape := "[", "]"
+		if n.Lazy {
+			openShape, closeShape = "(", ")"
+		}
+
+		_, err := fmt.Fprintf(w, "%s%s%s%s%s\n", indent, n.ID, openShape, mermaidQuote(label), closeShape)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, n := range nodes {
+		if len(children[n.ID]) == 0 {
+			continue
+		}
+
+		_, err := fmt.Fprintf(w, "%ssubgraph cluster_%s [%s]\n", indent, n.ID, mermaidQuote(n.Label()))
+		if err != nil {
+			return err
+		}
+
+		if err := writeMermaidNodes(w, children, n.ID, indent+"    "); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%send\n", indent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/bennydictor/taskset"
 	"github.com/bennydictor/taskset/properties"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
 )
 
 type disableTracingProperty struct{}
@@ -30,21 +32,39 @@ func WithSpanOpName(name string) taskset.Property {
 	}
 }
 
-// StartSpan determines when to start the opentracing span.
+// StartSpan determines which additional events are logged to the span.
+// Spans are always started as soon as the task starts running.
 type StartSpan int
 
 const (
-	// StartSpanOnTaskStart will start the span as soon as the task starts running.
+	// StartSpanOnTaskStart doesn't log any additional events.
 	StartSpanOnTaskStart = StartSpan(iota)
-	// StartSpanOnLastDepend will start the as soon as the task's last depend() call is done.
+	// StartSpanOnLastDepend logs a "last depend" event at the time the task's last depend() call is done.
 	// This mode is best used when all your tasks resolve all their dependencies as soon
-	// as they're started.
+	// as they're started, so that the event marks the start of the task's own work.
 	StartSpanOnLastDepend
 )
 
-type startTimeProperty struct{}
+type spanProperty struct{}
 
-// NewOpentracing creates a middleware that creates an opentracing span for each task.
+type lastDependProperty struct{}
+
+// Span returns the span of the task, or nil if the task isn't traced or hasn't started yet.
+// This function should only be used by middlewares.
+func Span(task *taskset.Task) opentracing.Span {
+	span, _ := task.Property(spanProperty{}).(opentracing.Span)
+	return span
+}
+
+// NewOpentracing creates a middleware that creates an opentracing span for each task
+// using the global tracer.
+//
+// The span is started before the task runs and is put into its context, so spans
+// created by the task's RunFunc are children of the task's span. If the task fails,
+// the span is tagged with error=true, and the error is logged.
+//
+// Every depend() call creates a "taskset:depend" span, which is a child of the task's span
+// and follows from the dependency's span, and lasts for as long as the task waited.
 //
 // Tracing can be disabled for a particular task using WithDisableTracing.
 //
@@ -52,7 +72,7 @@ type startTimeProperty struct{}
 // it's formed as "taskset:" + properties.Name(task). If a task has no name, the operation
 // name will be "taskset:task".
 //
-// Start time of each span can be controlled using StartSpan.
+// Additional events logged to each span can be controlled using StartSpan.
 func NewOpentracing(startSpan StartSpan) taskset.Middleware {
 	return taskset.Middleware{
 		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
@@ -60,14 +80,6 @@ func NewOpentracing(startSpan StartSpan) taskset.Middleware {
 				return next(ctx)
 			}
 
-			task.ModifyProperty(startTimeProperty{}, func(_ interface{}) interface{} {
-				return time.Now()
-			})
-
-			result := next(ctx)
-
-			startTime := task.Property(startTimeProperty{}).(time.Time)
-
 			opName, ok := task.Property(spanOpNameProperty{}).(string)
 			if !ok {
 				taskName := properties.Name(task)
@@ -78,21 +90,58 @@ func NewOpentracing(startSpan StartSpan) taskset.Middleware {
 				}
 			}
 
-			span, _ := opentracing.StartSpanFromContext(ctx, opName, opentracing.StartTime(startTime))
-			span.Finish()
+			span, ctx := opentracing.StartSpanFromContext(ctx, opName, opentracing.Tags{
+				"taskset.task.name": properties.Name(task),
+				"taskset.task.lazy": task.Lazy(),
+			})
+			task.ModifyProperty(spanProperty{}, func(_ interface{}) interface{} {
+				return span
+			})
+
+			result := next(ctx)
+
+			if result.Err != nil {
+				ext.LogError(span, result.Err)
+			}
+
+			var finish opentracing.FinishOptions
+			if lastDepend, ok := task.Property(lastDependProperty{}).(time.Time); ok {
+				finish.LogRecords = append(finish.LogRecords, opentracing.LogRecord{
+					Timestamp: lastDepend,
+					Fields:    []log.Field{log.String("event", "last depend")},
+				})
+			}
+			span.FinishWithOptions(finish)
 
 			return result
 		},
 		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-			if task.Property(disableTracingProperty{}) != nil {
+			span := Span(task)
+			if span == nil {
 				return next(ctx)
 			}
 
+			start := time.Now()
 			result := next(ctx)
+			end := time.Now()
+
+			options := []opentracing.StartSpanOption{
+				opentracing.ChildOf(span.Context()),
+				opentracing.StartTime(start),
+				opentracing.Tag{Key: "taskset.dependency.name", Value: properties.Name(dependency)},
+			}
+			if dependencySpan := Span(dependency); dependencySpan != nil {
+				options = append(options, opentracing.FollowsFrom(dependencySpan.Context()))
+			}
+			dependSpan := span.Tracer().StartSpan("taskset:depend", options...)
+			if result.Err != nil {
+				ext.Error.Set(dependSpan, true)
+			}
+			dependSpan.FinishWithOptions(opentracing.FinishOptions{FinishTime: end})
 
 			if startSpan == StartSpanOnLastDepend {
-				task.ModifyProperty(startTimeProperty{}, func(_ interface{}) interface{} {
-					return time.Now()
+				task.ModifyProperty(lastDependProperty{}, func(_ interface{}) interface{} {
+					return end
 				})
 			}
 
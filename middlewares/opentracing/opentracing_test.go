@@ -0,0 +1,86 @@
+package opentracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	tasksetopentracing "github.com/bennydictor/taskset/middlewares/opentracing"
+	"github.com/bennydictor/taskset/properties"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestNewOpentracing(t *testing.T) {
+	ctx := context.Background()
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	taskSet := taskset.NewTaskSet(
+		tasksetopentracing.NewOpentracing(tasksetopentracing.StartSpanOnLastDepend),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		span, _ := opentracing.StartSpanFromContext(ctx, "inner")
+		span.Finish()
+		return nil, errors.New("fail")
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend(ctx, taskA)
+		return 1, nil
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		tasksetopentracing.WithDisableTracing,
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	spans := make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4: %v", len(spans), spans)
+	}
+
+	a, b, inner, depend := spans["taskset:A"], spans["taskset:B"], spans["inner"], spans["taskset:depend"]
+
+	if inner.ParentID != a.SpanContext.SpanID {
+		t.Errorf("inner span isn't a child of A")
+	}
+	if a.ParentID != b.SpanContext.SpanID {
+		t.Errorf("lazy task A isn't a child of B, which started it")
+	}
+	if depend.ParentID != b.SpanContext.SpanID {
+		t.Errorf("depend span isn't a child of B")
+	}
+
+	if a.Tag("error") != true {
+		t.Errorf("A: error tag = %v, want true", a.Tag("error"))
+	}
+	if b.Tag("error") != nil {
+		t.Errorf("B: error tag = %v, want none", b.Tag("error"))
+	}
+	if logs := a.Logs(); len(logs) != 1 || logs[0].Fields[0].ValueString != "error" {
+		t.Errorf("A: logs = %v, want the error", logs)
+	}
+
+	if logs := b.Logs(); len(logs) != 1 || logs[0].Fields[0].ValueString != "last depend" {
+		t.Errorf("B: logs = %v, want the last depend event", logs)
+	}
+	if !b.StartTime.Before(depend.StartTime) {
+		t.Errorf("B didn't start before its depend() call")
+	}
+}
@@ -0,0 +1,131 @@
+// Package otel provides a middleware that traces tasks using OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type disableTracingProperty struct{}
+
+// WithDisableTracing disables tracing for a particular task.
+var WithDisableTracing taskset.Property = func(task *taskset.Task) {
+	task.ModifyProperty(disableTracingProperty{}, func(_ interface{}) interface{} {
+		return struct{}{}
+	})
+}
+
+type spanNameProperty struct{}
+
+// WithSpanName sets the span name for a task.
+func WithSpanName(name string) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(spanNameProperty{}, func(_ interface{}) interface{} {
+			return name
+		})
+	}
+}
+
+type spanProperty struct{}
+
+// Span returns the span of the task, or nil if the task isn't traced or hasn't started yet.
+// This function should only be used by middlewares.
+func Span(task *taskset.Task) trace.Span {
+	span, _ := task.Property(spanProperty{}).(trace.Span)
+	return span
+}
+
+const (
+	// TaskNameKey is the attribute key for properties.Name of a task.
+	TaskNameKey = attribute.Key("taskset.task.name")
+	// TaskLazyKey is the attribute key for whether a task is lazy.
+	TaskLazyKey = attribute.Key("taskset.task.lazy")
+	// DependencyNameKey is the attribute key for properties.Name of a dependency.
+	DependencyNameKey = attribute.Key("taskset.dependency.name")
+	// WaitKey is the attribute key for the time in seconds a task has waited for a dependency.
+	WaitKey = attribute.Key("taskset.depend.wait_seconds")
+)
+
+// NewOpenTelemetry creates a middleware that creates an OpenTelemetry span for each task.
+//
+// The span is started before the task runs and is put into its context, so spans
+// created by the task's RunFunc are children of the task's span. If the task fails,
+// its error is recorded, and the span's status is set to Error.
+//
+// Every depend() call adds "depend start" and "depend end" events to the task's span,
+// and a link to the dependency's span.
+//
+// Tracing can be disabled for a particular task using WithDisableTracing.
+//
+// The name of the spans can be set using WithSpanName. If no name is set, it's formed
+// as "taskset:" + properties.Name(task). If a task has no name, the span name will be "taskset:task".
+func NewOpenTelemetry(tracer trace.Tracer) taskset.Middleware {
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if task.Property(disableTracingProperty{}) != nil {
+				return next(ctx)
+			}
+
+			name, ok := task.Property(spanNameProperty{}).(string)
+			if !ok {
+				taskName := properties.Name(task)
+				if taskName != "" {
+					name = fmt.Sprintf("taskset:%s", taskName)
+				} else {
+					name = "taskset:task"
+				}
+			}
+
+			ctx, span := tracer.Start(ctx, name, trace.WithAttributes(
+				TaskNameKey.String(properties.Name(task)),
+				TaskLazyKey.Bool(task.Lazy()),
+			))
+			defer span.End()
+
+			task.ModifyProperty(spanProperty{}, func(_ interface{}) interface{} {
+				return span
+			})
+
+			result := next(ctx)
+
+			if result.Err != nil {
+				span.RecordError(result.Err)
+				span.SetStatus(codes.Error, result.Err.Error())
+			}
+
+			return result
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			span := Span(task)
+			if span == nil {
+				return next(ctx)
+			}
+
+			dependencyName := DependencyNameKey.String(properties.Name(dependency))
+			span.AddEvent("depend start", trace.WithAttributes(dependencyName))
+			start := time.Now()
+
+			result := next(ctx)
+
+			span.AddEvent("depend end", trace.WithAttributes(
+				dependencyName,
+				WaitKey.Float64(time.Since(start).Seconds()),
+			))
+			if dependencySpan := Span(dependency); dependencySpan != nil {
+				span.AddLink(trace.Link{
+					SpanContext: dependencySpan.SpanContext(),
+					Attributes:  []attribute.KeyValue{dependencyName},
+				})
+			}
+
+			return result
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	tasksetotel "github.com/bennydictor/taskset/middlewares/otel"
+	"github.com/bennydictor/taskset/properties"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewOpenTelemetry(t *testing.T) {
+	ctx := context.Background()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+
+	taskSet := taskset.NewTaskSet(
+		tasksetotel.NewOpenTelemetry(tracer),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		_, span := tracer.Start(ctx, "inner")
+		span.End()
+		return nil, errors.New("fail")
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, depend(ctx, taskA).Err
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		tasksetotel.WithDisableTracing,
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3: %v", len(spans), spans)
+	}
+
+	a, b, inner := spans["taskset:A"], spans["taskset:B"], spans["inner"]
+
+	if inner.Parent.SpanID() != a.SpanContext.SpanID() {
+		t.Errorf("inner span isn't a child of A")
+	}
+	if a.Parent.SpanID() != b.SpanContext.SpanID() {
+		t.Errorf("lazy task A isn't a child of B, which started it")
+	}
+
+	for _, span := range []tracetest.SpanStub{a, b} {
+		if span.Status.Code != codes.Error || span.Status.Description != "fail" {
+			t.Errorf("%s: status = %v, want error", span.Name, span.Status)
+		}
+	}
+	if len(a.Events) != 1 || a.Events[0].Name != "exception" {
+		t.Errorf("A: events = %v, want the recorded error", a.Events)
+	}
+
+	if len(b.Links) != 1 || b.Links[0].SpanContext.SpanID() != a.SpanContext.SpanID() {
+		t.Errorf("B: links = %v, want a link to A", b.Links)
+	}
+
+	var events []string
+	for _, e := range b.Events {
+		events = append(events, e.Name)
+	}
+	if len(events) != 3 || events[0] != "depend start" || events[1] != "depend end" || events[2] != "exception" {
+		t.Errorf("B: events = %v, want depend start, depend end, exception", events)
+	}
+}
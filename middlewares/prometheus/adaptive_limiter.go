@@ -0,0 +1,37 @@
+package prometheus
+
+import (
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type adaptiveLimitCollector struct {
+	limiter *middlewares.AdaptiveLimiter
+	desc    *prometheus.Desc
+}
+
+// NewAdaptiveLimitCollector creates a collector that reports the current limits of an adaptive limiter
+// as a gauge described by opts. The gauge has a single variable label, keyLabel, with the limiter's keys.
+func NewAdaptiveLimitCollector(limiter *middlewares.AdaptiveLimiter, opts prometheus.GaugeOpts, keyLabel string) prometheus.Collector {
+	return adaptiveLimitCollector{
+		limiter: limiter,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			opts.Help,
+			[]string{keyLabel},
+			opts.ConstLabels,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c adaptiveLimitCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c adaptiveLimitCollector) Collect(metrics chan<- prometheus.Metric) {
+	for key, limit := range c.limiter.Limits() {
+		metrics <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(limit), key)
+	}
+}
@@ -0,0 +1,46 @@
+package prometheus_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	tasksetprometheus "github.com/bennydictor/taskset/middlewares/prometheus"
+	"github.com/bennydictor/taskset/properties"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewAdaptiveLimitCollector(t *testing.T) {
+	ctx := context.Background()
+
+	limiter := middlewares.NewAdaptiveLimiter(middlewares.AdaptiveLimiterOptions{
+		InitialLimit: 3,
+		Key:          properties.Group,
+	})
+
+	taskSet := taskset.NewTaskSet(limiter.Middleware())
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		properties.WithGroup("api"),
+	)
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	collector := tasksetprometheus.NewAdaptiveLimitCollector(limiter, prometheus.GaugeOpts{
+		Name: "adaptive_limit",
+		Help: "Adaptive concurrency limit.",
+	}, "group")
+
+	expected := `
+		# HELP adaptive_limit Adaptive concurrency limit.
+		# TYPE adaptive_limit gauge
+		adaptive_limit{group="api"} 3
+	`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
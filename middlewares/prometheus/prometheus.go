@@ -27,6 +27,18 @@ type Metrics struct {
 	Success *prometheus.CounterVec
 	// Failure is used to report each task's failed execution.
 	Failure *prometheus.CounterVec
+	// InFlight is used to report the number of tasks currently running,
+	// including the ones waiting in depend() calls.
+	InFlight *prometheus.GaugeVec
+	// DependWait is used to report the time in seconds each depend() call waits for.
+	// It's reported with label values of the task, followed by label values of the dependency.
+	DependWait *prometheus.HistogramVec
+	// LazyTriggered is used to report each lazy task started by a depend() call.
+	LazyTriggered *prometheus.CounterVec
+
+	// LabelValues returns the label values to report a task's metrics with.
+	// If LabelValues is nil, {properties.Name(task)} is used.
+	LabelValues func(task *taskset.Task) []string
 }
 
 type timerProperty struct{}
@@ -40,16 +52,27 @@ type timer struct {
 // NewPrometheus creates a middleware that reports basic metrics on each task.
 //
 // Metric collection can be disabled for a particular task using WithDisableMetrics.
+// Nothing is reported for tasks run by taskset.DryRun.
 //
-// Every metric is reported using label values equal to {properties.Name(task)}.
-// If any of the metrics are nil, then that metric won't be reported.
+// Every metric is reported using label values returned by metrics.LabelValues,
+// which default to {properties.Name(task)}. If any of the metrics are nil,
+// then that metric won't be reported.
 func NewPrometheus(metrics Metrics) taskset.Middleware {
+	labelValues := metrics.LabelValues
+	if labelValues == nil {
+		labelValues = func(task *taskset.Task) []string {
+			return []string{properties.Name(task)}
+		}
+	}
+
 	return taskset.Middleware{
 		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-			if task.Property(disableMetricsProperty{}) != nil {
+			if task.Property(disableMetricsProperty{}) != nil || taskset.IsDryRun(ctx) {
 				return next(ctx)
 			}
 
+			labels := labelValues(task)
+
 			if metrics.Duration != nil {
 				task.ModifyProperty(timerProperty{}, func(_ interface{}) interface{} {
 					return timer{
@@ -58,26 +81,41 @@ func NewPrometheus(metrics Metrics) taskset.Middleware {
 				})
 			}
 
+			if task.Lazy() && metrics.LazyTriggered != nil {
+				counter, err := metrics.LazyTriggered.GetMetricWithLabelValues(labels...)
+				if err == nil {
+					counter.Inc()
+				}
+			}
+
+			if metrics.InFlight != nil {
+				gauge, err := metrics.InFlight.GetMetricWithLabelValues(labels...)
+				if err == nil {
+					gauge.Inc()
+					defer gauge.Dec()
+				}
+			}
+
 			result := next(ctx)
 
 			if metrics.Duration != nil {
 				t := task.Property(timerProperty{}).(timer)
 				duration := t.total + time.Since(t.start)
-				observer, err := metrics.Duration.GetMetricWithLabelValues(properties.Name(task))
+				observer, err := metrics.Duration.GetMetricWithLabelValues(labels...)
 				if err == nil {
 					observer.Observe(duration.Seconds())
 				}
 			}
 
 			if result.Err == nil && metrics.Success != nil {
-				counter, err := metrics.Success.GetMetricWithLabelValues(properties.Name(task))
+				counter, err := metrics.Success.GetMetricWithLabelValues(labels...)
 				if err == nil {
 					counter.Inc()
 				}
 			}
 
 			if result.Err != nil && metrics.Failure != nil {
-				counter, err := metrics.Failure.GetMetricWithLabelValues(properties.Name(task))
+				counter, err := metrics.Failure.GetMetricWithLabelValues(labels...)
 				if err == nil {
 					counter.Inc()
 				}
@@ -86,7 +124,7 @@ func NewPrometheus(metrics Metrics) taskset.Middleware {
 			return result
 		},
 		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
-			if task.Property(disableMetricsProperty{}) != nil {
+			if task.Property(disableMetricsProperty{}) != nil || taskset.IsDryRun(ctx) {
 				return next(ctx)
 			}
 
@@ -101,8 +139,16 @@ func NewPrometheus(metrics Metrics) taskset.Middleware {
 				})
 			}
 
+			start := time.Now()
 			result := next(ctx)
 
+			if metrics.DependWait != nil {
+				observer, err := metrics.DependWait.GetMetricWithLabelValues(append(labelValues(task), labelValues(dependency)...)...)
+				if err == nil {
+					observer.Observe(time.Since(start).Seconds())
+				}
+			}
+
 			if metrics.Duration != nil {
 				task.ModifyProperty(timerProperty{}, func(value interface{}) interface{} {
 					t := value.(timer)
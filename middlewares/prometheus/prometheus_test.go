@@ -0,0 +1,154 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	tasksetprometheus "github.com/bennydictor/taskset/middlewares/prometheus"
+	"github.com/bennydictor/taskset/properties"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type groupProperty struct{}
+
+func withGroup(group string) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(groupProperty{}, func(_ interface{}) interface{} {
+			return group
+		})
+	}
+}
+
+func TestNewPrometheus(t *testing.T) {
+	ctx := context.Background()
+
+	labels := []string{"task", "group"}
+	metrics := tasksetprometheus.Metrics{
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "task_duration_seconds", Help: "Task duration."}, labels),
+		Success:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "task_success_total", Help: "Successful tasks."}, labels),
+		Failure:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "task_failure_total", Help: "Failed tasks."}, labels),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "task_in_flight", Help: "Running tasks."}, labels),
+		DependWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "task_depend_wait_seconds", Help: "Depend wait time."},
+			[]string{"task", "group", "dependency", "dependency_group"}),
+		LazyTriggered: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "task_lazy_triggered_total", Help: "Lazy tasks triggered."}, labels),
+		LabelValues: func(task *taskset.Task) []string {
+			group, _ := task.Property(groupProperty{}).(string)
+			return []string{properties.Name(task), group}
+		},
+	}
+
+	taskSet := taskset.NewTaskSet(
+		tasksetprometheus.NewPrometheus(metrics),
+	)
+
+	inFlight := make(chan float64, 1)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		inFlight <- testutil.ToFloat64(metrics.InFlight.WithLabelValues("A", "db"))
+		return nil, errors.New("fail")
+	},
+		properties.WithName("A"),
+		withGroup("db"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend(ctx, taskA)
+		return nil, nil
+	},
+		properties.WithName("B"),
+		withGroup("web"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		properties.WithName("C"),
+		tasksetprometheus.WithDisableMetrics,
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	if got := <-inFlight; got != 1 {
+		t.Errorf("in flight while running = %v, want 1", got)
+	}
+
+	expected := `
+		# HELP task_failure_total Failed tasks.
+		# TYPE task_failure_total counter
+		task_failure_total{group="db",task="A"} 1
+		# HELP task_in_flight Running tasks.
+		# TYPE task_in_flight gauge
+		task_in_flight{group="db",task="A"} 0
+		task_in_flight{group="web",task="B"} 0
+		# HELP task_lazy_triggered_total Lazy tasks triggered.
+		# TYPE task_lazy_triggered_total counter
+		task_lazy_triggered_total{group="db",task="A"} 1
+		# HELP task_success_total Successful tasks.
+		# TYPE task_success_total counter
+		task_success_total{group="web",task="B"} 1
+	`
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics.Failure, metrics.InFlight, metrics.LazyTriggered, metrics.Success)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(metrics.DependWait); n != 1 {
+		t.Errorf("depend wait series = %d, want 1", n)
+	}
+	if n := testutil.CollectAndCount(metrics.Duration); n != 2 {
+		t.Errorf("duration series = %d, want 2", n)
+	}
+}
+
+func TestNewPrometheus_dryRun(t *testing.T) {
+	ctx := context.Background()
+
+	labels := []string{"task"}
+	metrics := tasksetprometheus.Metrics{
+		Duration:      prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "task_duration_seconds", Help: "Task duration."}, labels),
+		Success:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "task_success_total", Help: "Successful tasks."}, labels),
+		InFlight:      prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "task_in_flight", Help: "Running tasks."}, labels),
+		DependWait:    prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "task_depend_wait_seconds", Help: "Depend wait time."}, []string{"task", "dependency"}),
+		LazyTriggered: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "task_lazy_triggered_total", Help: "Lazy tasks triggered."}, labels),
+	}
+
+	taskSet := taskset.NewTaskSet(
+		tasksetprometheus.NewPrometheus(metrics),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend(ctx, taskA)
+		return nil, nil
+	},
+		properties.WithName("B"),
+		taskset.WithDependencies(taskA),
+	)
+
+	if err := taskSet.DryRun(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, collector := range map[string]prometheus.Collector{
+		"duration":       metrics.Duration,
+		"success":        metrics.Success,
+		"in flight":      metrics.InFlight,
+		"depend wait":    metrics.DependWait,
+		"lazy triggered": metrics.LazyTriggered,
+	} {
+		if n := testutil.CollectAndCount(collector); n != 0 {
+			t.Errorf("%s series = %d, want 0", name, n)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SetMetrics is a set of metrics reported by set prometheus middleware.
+// Each metric is reported once per task set execution, with a single label value: the set's name.
+type SetMetrics struct {
+	// Duration is used to report the wall time in seconds from TaskSet.Start
+	// until all non-lazy tasks finish.
+	Duration *prometheus.HistogramVec
+	// TasksRun is used to report the number of tasks run.
+	TasksRun *prometheus.HistogramVec
+	// LazyNotTriggered is used to report the number of lazy tasks that were never run.
+	LazyNotTriggered *prometheus.CounterVec
+	// MaxConcurrency is used to report the maximum number of tasks running at once.
+	// Tasks waiting in depend() calls are not counted as running.
+	MaxConcurrency *prometheus.HistogramVec
+	// Cancelled is used to report the number of tasks that failed with
+	// context.Canceled or context.DeadlineExceeded.
+	Cancelled *prometheus.CounterVec
+	// Failed is used to report the number of tasks that failed with any other error.
+	Failed *prometheus.CounterVec
+}
+
+type setExecutionKey struct{}
+
+type setExecution struct {
+	mu          sync.Mutex
+	ran         map[*taskset.Task]struct{}
+	dependCount map[*taskset.Task]uint
+	running     int
+	maxRunning  int
+	cancelled   int
+	failed      int
+}
+
+func (e *setExecution) setRunning(delta int) {
+	e.running += delta
+	if e.running > e.maxRunning {
+		e.maxRunning = e.running
+	}
+}
+
+// NewSetPrometheus creates a middleware that reports metrics on each execution of a task set.
+// The metrics are labeled with the task set's name, so create the task set with NewNamedTaskSet:
+//
+//	taskSet := taskset.NewNamedTaskSet("checkout", tasksetprometheus.NewSetPrometheus(setMetrics))
+//
+// Tasks of child task sets created by NewSub are counted as a part of their parent's execution.
+// If any of the metrics are nil, then that metric won't be reported.
+// Nothing is reported for a dry run, see taskset.DryRun.
+func NewSetPrometheus(metrics SetMetrics) taskset.Middleware {
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			e, ok := ctx.Value(setExecutionKey{}).(*setExecution)
+			if !ok {
+				return next(ctx)
+			}
+
+			e.mu.Lock()
+			e.ran[task] = struct{}{}
+			e.setRunning(1)
+			e.mu.Unlock()
+
+			result := next(ctx)
+
+			e.mu.Lock()
+			e.setRunning(-1)
+			if errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, context.DeadlineExceeded) {
+				e.cancelled++
+			} else if result.Err != nil {
+				e.failed++
+			}
+			e.mu.Unlock()
+
+			return result
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			e, ok := ctx.Value(setExecutionKey{}).(*setExecution)
+			if !ok {
+				return next(ctx)
+			}
+
+			e.mu.Lock()
+			if e.dependCount[task] == 0 {
+				e.setRunning(-1)
+			}
+			e.dependCount[task]++
+			e.mu.Unlock()
+
+			result := next(ctx)
+
+			e.mu.Lock()
+			e.dependCount[task]--
+			if e.dependCount[task] == 0 {
+				delete(e.dependCount, task)
+				e.setRunning(1)
+			}
+			e.mu.Unlock()
+
+			return result
+		},
+		Start: func(ctx context.Context, taskSet *taskset.TaskSet, next func(ctx context.Context)) {
+			if taskset.IsDryRun(ctx) {
+				next(ctx)
+				return
+			}
+
+			e := &setExecution{
+				ran:         make(map[*taskset.Task]struct{}),
+				dependCount: make(map[*taskset.Task]uint),
+			}
+
+			start := time.Now()
+			next(context.WithValue(ctx, setExecutionKey{}, e))
+			duration := time.Since(start)
+
+			e.mu.Lock()
+			defer e.mu.Unlock()
+
+			name := taskSet.Name()
+			lazyNotTriggered := 0
+			for _, task := range taskSet.Tasks() {
+				if _, ok := e.ran[task]; !ok && task.Lazy() {
+					lazyNotTriggered++
+				}
+			}
+
+			observe(metrics.Duration, name, duration.Seconds())
+			observe(metrics.TasksRun, name, float64(len(e.ran)))
+			observe(metrics.MaxConcurrency, name, float64(e.maxRunning))
+			add(metrics.LazyNotTriggered, name, float64(lazyNotTriggered))
+			add(metrics.Cancelled, name, float64(e.cancelled))
+			add(metrics.Failed, name, float64(e.failed))
+		},
+	}
+}
+
+func observe(metric *prometheus.HistogramVec, name string, value float64) {
+	if metric == nil {
+		return
+	}
+	observer, err := metric.GetMetricWithLabelValues(name)
+	if err == nil {
+		observer.Observe(value)
+	}
+}
+
+func add(metric *prometheus.CounterVec, name string, value float64) {
+	if metric == nil {
+		return
+	}
+	counter, err := metric.GetMetricWithLabelValues(name)
+	if err == nil {
+		counter.Add(value)
+	}
+}
@@ -0,0 +1,127 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	tasksetprometheus "github.com/bennydictor/taskset/middlewares/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewSetPrometheus(t *testing.T) {
+	ctx := context.Background()
+
+	labels := []string{"set"}
+	metrics := tasksetprometheus.SetMetrics{
+		Duration:         prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "set_duration_seconds", Help: "Set duration."}, labels),
+		TasksRun:         prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "set_tasks_run", Help: "Tasks run.", Buckets: []float64{4}}, labels),
+		LazyNotTriggered: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "set_lazy_not_triggered_total", Help: "Lazy tasks not triggered."}, labels),
+		MaxConcurrency:   prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "set_max_concurrency", Help: "Max concurrency.", Buckets: []float64{3}}, labels),
+		Cancelled:        prometheus.NewCounterVec(prometheus.CounterOpts{Name: "set_cancelled_total", Help: "Cancelled tasks."}, labels),
+		Failed:           prometheus.NewCounterVec(prometheus.CounterOpts{Name: "set_failed_total", Help: "Failed tasks."}, labels),
+	}
+
+	taskSet := taskset.NewNamedTaskSet("test",
+		tasksetprometheus.NewSetPrometheus(metrics),
+	)
+
+	// All eager tasks wait for each other, so that exactly three tasks are running at once:
+	// B, C and D, and then C, D and A while B waits for A.
+	var started sync.WaitGroup
+	started.Add(3)
+	rendezvous := func() {
+		started.Done()
+		started.Wait()
+	}
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	})
+	taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	})
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		rendezvous()
+		depend(ctx, taskA)
+		return nil, nil
+	})
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		rendezvous()
+		return nil, errors.New("fail")
+	})
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		rendezvous()
+		return nil, context.Canceled
+	})
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	expected := `
+		# HELP set_cancelled_total Cancelled tasks.
+		# TYPE set_cancelled_total counter
+		set_cancelled_total{set="test"} 1
+		# HELP set_failed_total Failed tasks.
+		# TYPE set_failed_total counter
+		set_failed_total{set="test"} 1
+		# HELP set_lazy_not_triggered_total Lazy tasks not triggered.
+		# TYPE set_lazy_not_triggered_total counter
+		set_lazy_not_triggered_total{set="test"} 1
+		# HELP set_max_concurrency Max concurrency.
+		# TYPE set_max_concurrency histogram
+		set_max_concurrency_bucket{set="test",le="3"} 1
+		set_max_concurrency_bucket{set="test",le="+Inf"} 1
+		set_max_concurrency_sum{set="test"} 3
+		set_max_concurrency_count{set="test"} 1
+		# HELP set_tasks_run Tasks run.
+		# TYPE set_tasks_run histogram
+		set_tasks_run_bucket{set="test",le="4"} 1
+		set_tasks_run_bucket{set="test",le="+Inf"} 1
+		set_tasks_run_sum{set="test"} 4
+		set_tasks_run_count{set="test"} 1
+	`
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics.Cancelled, metrics.Failed, metrics.LazyNotTriggered, metrics.MaxConcurrency, metrics.TasksRun)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(metrics.Duration); n != 1 {
+		t.Errorf("duration series = %d, want 1", n)
+	}
+}
+
+func TestNewSetPrometheus_dryRun(t *testing.T) {
+	ctx := context.Background()
+
+	labels := []string{"set"}
+	metrics := tasksetprometheus.SetMetrics{
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "set_duration_seconds", Help: "Set duration."}, labels),
+		TasksRun: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "set_tasks_run", Help: "Tasks run."}, labels),
+	}
+
+	taskSet := taskset.NewNamedTaskSet("test",
+		tasksetprometheus.NewSetPrometheus(metrics),
+	)
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	})
+
+	if err := taskSet.DryRun(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, collector := range map[string]prometheus.Collector{
+		"duration":  metrics.Duration,
+		"tasks run": metrics.TasksRun,
+	} {
+		if n := testutil.CollectAndCount(collector); n != 0 {
+			t.Errorf("%s series = %d, want 0", name, n)
+		}
+	}
+}
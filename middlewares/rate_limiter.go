@@ -0,0 +1,105 @@
+package middlewares
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+)
+
+// TokenBucket is a token bucket rate limiter. It holds up to burst tokens,
+// and is refilled with rate tokens per second. A TokenBucket can be shared
+// by any number of task sets.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full TokenBucket, which allows rate events per second,
+// and bursts of up to burst events. Rate must be positive, and burst must not be negative.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if !(rate > 0) {
+		panic("token bucket rate must be positive")
+	}
+	if burst < 0 {
+		panic("token bucket burst must not be negative")
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait takes a token from the bucket, blocking until one is available.
+// If ctx is cancelled before that, Wait returns ctx.Err() and doesn't take a token.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	// Tokens may become negative, which reserves the future tokens for this call.
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// NewRateLimiter creates a middleware used to limit the rate at which tasks are started.
+// Before each task is run, rate limiter takes a token from the bucket of the task's group,
+// see properties.WithGroup. Tasks whose group has no bucket in buckets are not limited.
+// Tasks without a group use the bucket of the empty group.
+// No tokens are taken in a dry run, see taskset.DryRun.
+//
+// If the task's context is cancelled while waiting for a token, the task is not run,
+// and its result is ctx.Err().
+//
+// Pass rate limiter to NewTaskSet before the middlewares that measure task durations, like
+// prometheus or logging middlewares. Then the time spent waiting for a token
+// is excluded from a task's duration, just like the time spent in depend().
+func NewRateLimiter(buckets map[string]*TokenBucket) taskset.Middleware {
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			bucket, ok := buckets[properties.Group(task)]
+			if !ok || taskset.IsDryRun(ctx) {
+				return next(ctx)
+			}
+
+			if err := bucket.Wait(ctx); err != nil {
+				return taskset.Result{Err: err}
+			}
+
+			return next(ctx)
+		},
+	}
+}
@@ -0,0 +1,101 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleNewRateLimiter() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewRateLimiter(map[string]*middlewares.TokenBucket{
+			"api": middlewares.NewTokenBucket(2, 1),
+		}),
+	)
+
+	// The "api" tasks are started at 0s, 0.5s and 1s.
+	for i := 0; i < 3; i++ {
+		taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, nil
+		},
+			properties.WithGroup("api"),
+		)
+	}
+
+	// Tasks of other groups are not limited.
+	for i := 0; i < 3; i++ {
+		taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, nil
+		})
+	}
+
+	start := time.Now()
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+	totalTime := time.Since(start)
+
+	fmt.Printf("total time: %.0fs\n", totalTime.Seconds())
+	// Output: total time: 1s
+}
+
+func ExampleNewRateLimiter_cancel() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewRateLimiter(map[string]*middlewares.TokenBucket{
+			"": middlewares.NewTokenBucket(1, 1),
+		}),
+	)
+
+	taskA := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return "A", nil
+	})
+
+	taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return "B", nil
+	})
+
+	taskSet.Start(ctx)
+	taskSet.Wait(context.Background())
+
+	// Only one of the tasks gets a token before the context times out.
+	for _, err := range []error{
+		taskSet.Result(context.Background(), taskA).Err,
+		taskSet.Result(context.Background(), taskB).Err,
+	} {
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Output: context deadline exceeded
+}
+
+func TestNewTokenBucket_invalid(t *testing.T) {
+	for _, test := range []struct {
+		rate  float64
+		burst int
+	}{
+		{rate: 0, burst: 1},
+		{rate: -1, burst: 1},
+		{rate: math.NaN(), burst: 1},
+		{rate: 1, burst: -1},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewTokenBucket(%v, %v) didn't panic", test.rate, test.burst)
+				}
+			}()
+			middlewares.NewTokenBucket(test.rate, test.burst)
+		}()
+	}
+}
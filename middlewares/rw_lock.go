@@ -0,0 +1,202 @@
+package middlewares
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bennydictor/taskset"
+)
+
+var rwLockCount uint64
+
+// RWLock is a readers-writer lock, which can be locked with a context.
+// Unlike sync.RWMutex, its zero value is not usable, create it with NewRWLock.
+//
+// RWLock prefers writers: once a writer is waiting for the lock,
+// new readers wait until the writer is done, so that writers don't starve.
+// The write lock is *RWLock itself, and the read lock is RLocker.
+type RWLock struct {
+	id uint64
+
+	mu             sync.Mutex
+	readers        int
+	writer         bool
+	waitingWriters int
+	// changed is closed and replaced each time the lock may have become available.
+	changed chan struct{}
+}
+
+// NewRWLock creates a new unlocked RWLock.
+func NewRWLock() *RWLock {
+	return &RWLock{
+		id:      atomic.AddUint64(&rwLockCount, 1),
+		changed: make(chan struct{}),
+	}
+}
+
+func (l *RWLock) broadcast() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (l *RWLock) lock(ctx context.Context, write bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if write {
+		l.waitingWriters++
+	}
+
+	for {
+		if write && !l.writer && l.readers == 0 {
+			l.waitingWriters--
+			l.writer = true
+			return nil
+		}
+		if !write && !l.writer && l.waitingWriters == 0 {
+			l.readers++
+			return nil
+		}
+
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-changed:
+			l.mu.Lock()
+		case <-ctx.Done():
+			l.mu.Lock()
+			if write {
+				l.waitingWriters--
+				// Readers may have been waiting only for this writer.
+				l.broadcast()
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// Lock locks l for writing.
+func (l *RWLock) Lock() {
+	_ = l.lock(context.Background(), true)
+}
+
+// LockContext locks l for writing, blocking until it's available or ctx is done.
+// If ctx is done first, LockContext returns ctx.Err() without locking.
+func (l *RWLock) LockContext(ctx context.Context) error {
+	return l.lock(ctx, true)
+}
+
+// Unlock unlocks l for writing.
+func (l *RWLock) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.writer = false
+	l.broadcast()
+}
+
+// RLock locks l for reading.
+func (l *RWLock) RLock() {
+	_ = l.lock(context.Background(), false)
+}
+
+// RLockContext locks l for reading, blocking until it's available or ctx is done.
+// If ctx is done first, RLockContext returns ctx.Err() without locking.
+func (l *RWLock) RLockContext(ctx context.Context) error {
+	return l.lock(ctx, false)
+}
+
+// RUnlock unlocks l for reading.
+func (l *RWLock) RUnlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.readers--
+	if l.readers == 0 {
+		l.broadcast()
+	}
+}
+
+// RLocker returns a ContextLocker that locks l for reading.
+func (l *RWLock) RLocker() ContextLocker {
+	return rLocker{l}
+}
+
+type rLocker struct {
+	l *RWLock
+}
+
+func (r rLocker) Lock() { r.l.RLock() }
+
+func (r rLocker) LockContext(ctx context.Context) error { return r.l.RLockContext(ctx) }
+
+func (r rLocker) Unlock() { r.l.RUnlock() }
+
+type rwLocksProperty struct{}
+
+// WithReadLock adds a lock to a task to be locked for reading by concurrency limiter.
+// A task can have any number of read and write locks, in addition to the lock added by WithLock.
+// If several concurrency limiters are stacked, only the outermost one locks it.
+func WithReadLock(rw *RWLock) taskset.Property {
+	return withRWLock(rw, false)
+}
+
+// WithWriteLock adds a lock to a task to be locked for writing by concurrency limiter.
+// A task can have any number of read and write locks, in addition to the lock added by WithLock.
+// If several concurrency limiters are stacked, only the outermost one locks it.
+func WithWriteLock(rw *RWLock) taskset.Property {
+	return withRWLock(rw, true)
+}
+
+func withRWLock(rw *RWLock, write bool) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(rwLocksProperty{}, func(value interface{}) interface{} {
+			locks, _ := value.(map[*RWLock]bool)
+			result := make(map[*RWLock]bool, len(locks)+1)
+			for l, w := range locks {
+				result[l] = w
+			}
+			// Writing includes reading.
+			result[rw] = result[rw] || write
+			return result
+		})
+	}
+}
+
+type rwLocksClaimedProperty struct{}
+
+// rwLocks returns the read and write locks of a task, ordered by RWLock creation,
+// so that all tasks lock them in the same order.
+//
+// Only the first call for a task returns the locks, so that if several concurrency limiters
+// are stacked, the outermost one locks them, and the others don't lock them again.
+func rwLocks(task *taskset.Task) multiLocker {
+	claimed := false
+	task.ModifyProperty(rwLocksClaimedProperty{}, func(value interface{}) interface{} {
+		claimed = value != nil
+		return struct{}{}
+	})
+	if claimed {
+		return nil
+	}
+
+	locks, _ := task.Property(rwLocksProperty{}).(map[*RWLock]bool)
+
+	rws := make([]*RWLock, 0, len(locks))
+	for rw := range locks {
+		rws = append(rws, rw)
+	}
+	sort.Slice(rws, func(i, j int) bool { return rws[i].id < rws[j].id })
+
+	result := make(multiLocker, len(rws))
+	for i, rw := range rws {
+		if locks[rw] {
+			result[i] = rw
+		} else {
+			result[i] = rw.RLocker()
+		}
+	}
+	return result
+}
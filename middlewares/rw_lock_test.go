@@ -0,0 +1,121 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+)
+
+func ExampleWithReadLock() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewConcurrencyLimiter(nil),
+	)
+
+	cache := middlewares.NewRWLock()
+
+	sleep := func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	}
+
+	// The readers run together, and the writer runs alone.
+	taskSet.New(sleep, middlewares.WithReadLock(cache))
+	taskSet.New(sleep, middlewares.WithReadLock(cache))
+	writer := taskSet.NewLazy(sleep, middlewares.WithWriteLock(cache))
+
+	// Start the writer after the readers have the lock.
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return depend(ctx, writer).Value, nil
+	})
+
+	start := time.Now()
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+	totalTime := time.Since(start)
+
+	fmt.Printf("total time: %.1fs\n", totalTime.Seconds())
+	// Output: total time: 0.4s
+}
+
+func ExampleRWLock() {
+	rw := middlewares.NewRWLock()
+	var wg sync.WaitGroup
+
+	rw.RLock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rw.Lock()
+		fmt.Println("writer")
+		rw.Unlock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The writer is waiting, so the new reader waits for it, even though the lock is held by a reader.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rw.RLock()
+		fmt.Println("reader")
+		rw.RUnlock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	rw.RUnlock()
+	wg.Wait()
+
+	// Output:
+	// writer
+	// reader
+}
+
+func ExampleRWLock_LockContext() {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rw := middlewares.NewRWLock()
+	rw.RLock()
+
+	fmt.Println(rw.LockContext(ctx))
+
+	// The cancelled writer no longer blocks new readers.
+	fmt.Println(rw.RLockContext(context.Background()))
+
+	// Output:
+	// context deadline exceeded
+	// <nil>
+}
+
+func TestWithWriteLock_stackedLimiters(t *testing.T) {
+	ctx := context.Background()
+
+	rw := middlewares.NewRWLock()
+	taskSet := taskset.NewTaskSet(
+		middlewares.NewConcurrencyLimiter(nil),
+		middlewares.NewKeyedConcurrencyLimiter(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		middlewares.WithWriteLock(rw),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, depend(ctx, taskA).Err
+	},
+		middlewares.WithWriteLock(rw),
+	)
+
+	taskSet.Start(ctx)
+	waitOrFail(t, taskSet)
+}
@@ -0,0 +1,9 @@
+module github.com/bennydictor/taskset/middlewares/slog
+
+go 1.21
+
+replace github.com/bennydictor/taskset => ../..
+
+require github.com/bennydictor/taskset v0.0.0-00010101000000-000000000000
+
+require golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
@@ -0,0 +1,136 @@
+package slog
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+type disableLoggingProperty struct{}
+
+// WithDisableLogging disables logging for a particular task.
+var WithDisableLogging taskset.Property = func(task *taskset.Task) {
+	task.ModifyProperty(disableLoggingProperty{}, func(_ interface{}) interface{} {
+		return struct{}{}
+	})
+}
+
+type levelProperty struct{}
+
+// WithLevel overrides the level of a task's "task started" and "task done" records,
+// which are logged at slog.LevelInfo by default.
+func WithLevel(level slog.Level) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(levelProperty{}, func(_ interface{}) interface{} {
+			return level
+		})
+	}
+}
+
+func level(task *taskset.Task) slog.Level {
+	level, ok := task.Property(levelProperty{}).(slog.Level)
+	if !ok {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+type loggerKey struct{}
+
+// LoggerFromContext returns the task-scoped logger put into the context by NewLogger.
+// Records logged with it carry the task attribute.
+// If there is no such logger, LoggerFromContext returns slog.Default().
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return logger
+}
+
+// dependTimerProperty is the key of a logger's DependTimer of a task.
+// Each logger has its own id, so that stacked loggers don't overwrite each other's timers.
+type dependTimerProperty struct {
+	id uint64
+}
+
+var lastLoggerID uint64
+
+// NewLogger creates a logging middleware. It will log a "task started" and a "task done" record
+// at slog.LevelInfo when a task is started and successfully finished, an error record when
+// a task is failed, and debug records when every depend() call starts and ends.
+//
+// The "task done" and "task failed" records have a "duration" attribute with the task's
+// total run time, and a "wait" attribute with the time it spent in depend() calls.
+// The "depend end" records have a "wait" attribute with the time the depend() call took.
+//
+// The level of "task started" and "task done" records can be overridden for a particular
+// task using WithLevel. Logging can be disabled for a particular task using WithDisableLogging.
+//
+// The task's RunFunc gets a context with a task-scoped logger, see LoggerFromContext.
+func NewLogger(logger *slog.Logger) taskset.Middleware {
+	timerKey := dependTimerProperty{atomic.AddUint64(&lastLoggerID, 1)}
+
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if task.Property(disableLoggingProperty{}) != nil {
+				return next(ctx)
+			}
+
+			log := logger.With(slog.String("task", properties.Name(task)))
+			level := level(task)
+			log.Log(ctx, level, "task started")
+
+			timer := &middlewares.DependTimer{}
+			task.ModifyProperty(timerKey, func(_ interface{}) interface{} {
+				return timer
+			})
+
+			start := time.Now()
+			result := next(context.WithValue(ctx, loggerKey{}, log))
+			duration := time.Since(start)
+
+			wait := timer.Total()
+			attrs := []slog.Attr{
+				slog.Duration("duration", duration),
+				slog.Duration("wait", wait),
+			}
+
+			if result.Err != nil {
+				attrs = append(attrs, slog.Any("error", result.Err))
+				log.LogAttrs(ctx, slog.LevelError, "task failed", attrs...)
+			} else {
+				log.LogAttrs(ctx, level, "task done", attrs...)
+			}
+
+			return result
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if task.Property(disableLoggingProperty{}) != nil {
+				return next(ctx)
+			}
+
+			log := logger.With(
+				slog.String("task", properties.Name(task)),
+				slog.String("dependency", properties.Name(dependency)),
+			)
+
+			if timer, ok := task.Property(timerKey).(*middlewares.DependTimer); ok {
+				timer.Start()
+				defer timer.Stop()
+			}
+
+			log.DebugContext(ctx, "depend start")
+			start := time.Now()
+			result := next(ctx)
+			log.LogAttrs(ctx, slog.LevelDebug, "depend end", slog.Duration("wait", time.Since(start)))
+
+			return result
+		},
+	}
+}
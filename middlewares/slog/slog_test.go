@@ -0,0 +1,110 @@
+package slog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	tasksetslog "github.com/bennydictor/taskset/middlewares/slog"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleNewLogger() {
+	ctx := context.Background()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			// Drop the attributes that differ between runs.
+			switch attr.Key {
+			case slog.TimeKey, "duration", "wait":
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+
+	taskSet := taskset.NewTaskSet(
+		tasksetslog.NewLogger(logger),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		tasksetslog.LoggerFromContext(ctx).Info("computing")
+		return nil, errors.New("oops")
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend(ctx, taskA)
+		return nil, nil
+	},
+		properties.WithName("B"),
+		tasksetslog.WithLevel(slog.LevelWarn),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Output:
+	// level=WARN msg="task started" task=B
+	// level=DEBUG msg="depend start" task=B dependency=A
+	// level=INFO msg="task started" task=A
+	// level=INFO msg=computing task=A
+	// level=ERROR msg="task failed" task=A error=oops
+	// level=DEBUG msg="depend end" task=B dependency=A
+	// level=WARN msg="task done" task=B
+}
+
+func TestNewLogger_stacked(t *testing.T) {
+	ctx := context.Background()
+
+	var outer, inner bytes.Buffer
+	taskSet := taskset.NewTaskSet(
+		tasksetslog.NewLogger(slog.New(slog.NewJSONHandler(&outer, nil))),
+		tasksetslog.NewLogger(slog.New(slog.NewJSONHandler(&inner, nil))),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return nil, nil
+	},
+		properties.WithName("A"),
+	)
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, depend(ctx, taskA).Err
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Each logger measures B's depend() wait with its own timer.
+	for name, buf := range map[string]*bytes.Buffer{"outer": &outer, "inner": &inner} {
+		var wait time.Duration = -1
+		decoder := json.NewDecoder(buf)
+		for decoder.More() {
+			var record struct {
+				Msg  string        `json:"msg"`
+				Task string        `json:"task"`
+				Wait time.Duration `json:"wait"`
+			}
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			if record.Msg == "task done" && record.Task == "B" {
+				wait = record.Wait
+			}
+		}
+		if wait < 100*time.Millisecond {
+			t.Errorf("%s logger: wait of B = %v, want at least 100ms", name, wait)
+		}
+	}
+}
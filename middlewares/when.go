@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/bennydictor/taskset"
+)
+
+// When creates a middleware that applies mw only to tasks for which predicate
+// returns true. For Depend, the predicate is checked against the task that declares
+// the dependency. For other tasks, When does nothing.
+// Start runs for the whole task set rather than for a task, so it's kept unchanged.
+//
+// To apply a middleware to a handful of known tasks, prefer taskset.WithMiddleware.
+func When(predicate func(task *taskset.Task) bool, mw taskset.Middleware) taskset.Middleware {
+	result := taskset.Middleware{
+		Start: mw.Start,
+	}
+
+	if mw.Run != nil {
+		result.Run = func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if !predicate(task) {
+				return next(ctx)
+			}
+			return mw.Run(ctx, task, next)
+		}
+	}
+
+	if mw.Depend != nil {
+		result.Depend = func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if !predicate(task) {
+				return next(ctx)
+			}
+			return mw.Depend(ctx, task, dependency, next)
+		}
+	}
+
+	return result
+}
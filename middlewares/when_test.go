@@ -0,0 +1,76 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleWhen() {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var tasksSeen []string
+
+	recorder := taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			mu.Lock()
+			tasksSeen = append(tasksSeen, properties.Name(task))
+			mu.Unlock()
+			return next(ctx)
+		},
+	}
+
+	taskSet := taskset.NewTaskSet(
+		middlewares.When(func(task *taskset.Task) bool {
+			return strings.HasPrefix(properties.Name(task), "db:")
+		}, recorder),
+	)
+
+	for _, name := range []string{"db:users", "render", "db:orders"} {
+		taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, nil
+		},
+			properties.WithName(name),
+		)
+	}
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	sort.Strings(tasksSeen)
+	fmt.Println(strings.Join(tasksSeen, ", "))
+
+	// Output: db:orders, db:users
+}
+
+func TestWhen_start(t *testing.T) {
+	ctx := context.Background()
+
+	started := false
+	taskSet := taskset.NewTaskSet(
+		middlewares.When(func(task *taskset.Task) bool {
+			return false
+		}, taskset.Middleware{
+			Start: func(ctx context.Context, taskSet *taskset.TaskSet, next func(ctx context.Context)) {
+				started = true
+				next(ctx)
+			},
+		}),
+	)
+	taskSet.New(succeed)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	if !started {
+		t.Error("Start of the wrapped middleware wasn't called")
+	}
+}
@@ -2,10 +2,14 @@ package zap
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
 	"github.com/bennydictor/taskset/properties"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type disableLoggingProperty struct{}
@@ -17,27 +21,106 @@ var WithDisableLogging taskset.Property = func(task *taskset.Task) {
 	})
 }
 
+type minLevelProperty struct{}
+
+// WithMinLevel sets the minimum level of messages logged by a particular task,
+// both by the middleware and by the task-scoped logger. It can only raise the level
+// of the logger passed to NewLogger, not lower it.
+func WithMinLevel(level zapcore.Level) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(minLevelProperty{}, func(_ interface{}) interface{} {
+			return level
+		})
+	}
+}
+
+type slowThresholdProperty struct{}
+
+// WithSlowThreshold makes the "task done" message of a particular task be logged
+// with warn level, if its self time is at least threshold.
+func WithSlowThreshold(threshold time.Duration) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(slowThresholdProperty{}, func(_ interface{}) interface{} {
+			return threshold
+		})
+	}
+}
+
+type loggerKey struct{}
+
+// LoggerFromContext returns the task-scoped logger put into the context by NewLogger.
+// Messages logged with it carry the task field. If there is no such logger,
+// LoggerFromContext returns the global logger, zap.L().
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*zap.Logger)
+	if !ok {
+		return zap.L()
+	}
+	return logger
+}
+
+// dependTimerProperty is the key of a logger's DependTimer of a task.
+// Each logger has its own id, so that stacked loggers don't overwrite each other's timers.
+type dependTimerProperty struct {
+	id uint64
+}
+
+var lastLoggerID uint64
+
+func taskLogger(logger *zap.Logger, task *taskset.Task) *zap.Logger {
+	if level, ok := task.Property(minLevelProperty{}).(zapcore.Level); ok {
+		logger = logger.WithOptions(zap.IncreaseLevel(level))
+	}
+	return logger.With(zap.String("task", properties.Name(task)))
+}
+
 // NewLogger creates a logging middleware. It will log an info message
 // when a task is started and successfully finished, and error message when a task is failed,
 // and a debug message for every depend() call.
 //
+// The "task done" and "task failed" messages have a duration field with the task's total run time,
+// a depend_wait field with the time it spent in depend() calls, and a self_time field with the rest.
+// The "depend end" messages have a duration field with the time the depend() call took.
+//
+// The minimum level of a particular task's messages can be set using WithMinLevel.
+// Slow tasks can be logged with warn level using WithSlowThreshold.
 // Logging can be disabled for a particular task using WithDisableLogging.
+//
+// The task's RunFunc gets a context with a task-scoped logger, see LoggerFromContext.
 func NewLogger(logger *zap.Logger) taskset.Middleware {
+	timerKey := dependTimerProperty{atomic.AddUint64(&lastLoggerID, 1)}
+
 	return taskset.Middleware{
 		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
 			if task.Property(disableLoggingProperty{}) != nil {
 				return next(ctx)
 			}
 
-			log := logger.With(zap.String("task", properties.Name(task)))
+			log := taskLogger(logger, task)
 			log.Info("task started")
 
-			result := next(ctx)
+			timer := &middlewares.DependTimer{}
+			task.ModifyProperty(timerKey, func(_ interface{}) interface{} {
+				return timer
+			})
+
+			start := time.Now()
+			result := next(context.WithValue(ctx, loggerKey{}, log))
+			duration := time.Since(start)
+
+			wait := timer.Total()
+			fields := []zap.Field{
+				zap.Duration("duration", duration),
+				zap.Duration("self_time", duration-wait),
+				zap.Duration("depend_wait", wait),
+			}
 
 			if result.Err != nil {
-				log.Error("task failed", zap.Error(result.Err))
+				log.Error("task failed", append(fields, zap.Error(result.Err))...)
+			} else if threshold, ok := task.Property(slowThresholdProperty{}).(time.Duration); ok && duration-wait >= threshold {
+				log.Warn("task done", fields...)
 			} else {
-				log.Info("task done")
+				log.Info("task done", fields...)
 			}
 
 			return result
@@ -47,14 +130,20 @@ func NewLogger(logger *zap.Logger) taskset.Middleware {
 				return next(ctx)
 			}
 
-			log := logger.With(
-				zap.String("task", properties.Name(task)),
+			log := taskLogger(logger, task).With(
 				zap.String("dependency", properties.Name(dependency)),
 			)
 
+			if timer, ok := task.Property(timerKey).(*middlewares.DependTimer); ok {
+				timer.Start()
+				defer timer.Stop()
+			}
+
 			log.Debug("depend start")
+			start := time.Now()
 			result := next(ctx)
-			log.Debug("depend end")
+			log.Debug("depend end", zap.Duration("duration", time.Since(start)))
+
 			return result
 		},
 	}
@@ -0,0 +1,117 @@
+package zap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	tasksetzap "github.com/bennydictor/taskset/middlewares/zap"
+	"github.com/bennydictor/taskset/properties"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewLogger(t *testing.T) {
+	ctx := context.Background()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	taskSet := taskset.NewTaskSet(
+		tasksetzap.NewLogger(zap.New(core)),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		tasksetzap.LoggerFromContext(ctx).Info("computing")
+		time.Sleep(100 * time.Millisecond)
+		return nil, nil
+	},
+		properties.WithName("A"),
+		tasksetzap.WithSlowThreshold(50*time.Millisecond),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		depend(ctx, taskA)
+		tasksetzap.LoggerFromContext(ctx).Info("not logged")
+		return nil, errors.New("oops")
+	},
+		properties.WithName("B"),
+		tasksetzap.WithMinLevel(zapcore.WarnLevel),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	type entry struct {
+		level   zapcore.Level
+		message string
+		task    interface{}
+	}
+	var got []entry
+	for _, e := range logs.AllUntimed() {
+		got = append(got, entry{e.Level, e.Message, e.ContextMap()["task"]})
+	}
+	want := []entry{
+		{zapcore.InfoLevel, "task started", "A"},
+		{zapcore.InfoLevel, "computing", "A"},
+		{zapcore.WarnLevel, "task done", "A"},
+		{zapcore.ErrorLevel, "task failed", "B"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	failed := logs.FilterMessage("task failed").All()[0].ContextMap()
+	duration := failed["duration"].(time.Duration)
+	selfTime := failed["self_time"].(time.Duration)
+	dependWait := failed["depend_wait"].(time.Duration)
+	if dependWait < 100*time.Millisecond {
+		t.Errorf("depend_wait = %v, want at least 100ms", dependWait)
+	}
+	if selfTime+dependWait != duration {
+		t.Errorf("self_time + depend_wait = %v, want duration %v", selfTime+dependWait, duration)
+	}
+}
+
+func TestNewLogger_stacked(t *testing.T) {
+	ctx := context.Background()
+
+	outerCore, outerLogs := observer.New(zapcore.InfoLevel)
+	innerCore, innerLogs := observer.New(zapcore.InfoLevel)
+	taskSet := taskset.NewTaskSet(
+		tasksetzap.NewLogger(zap.New(outerCore)),
+		tasksetzap.NewLogger(zap.New(innerCore)),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return nil, nil
+	},
+		properties.WithName("A"),
+	)
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, depend(ctx, taskA).Err
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	// Each logger measures B's depend() wait with its own timer.
+	for name, logs := range map[string]*observer.ObservedLogs{"outer": outerLogs, "inner": innerLogs} {
+		done := logs.FilterMessage("task done").FilterField(zap.String("task", "B")).All()
+		if len(done) != 1 {
+			t.Fatalf("%s logger: got %d \"task done\" entries of B, want 1", name, len(done))
+		}
+		if dependWait := done[0].ContextMap()["depend_wait"].(time.Duration); dependWait < 100*time.Millisecond {
+			t.Errorf("%s logger: depend_wait = %v, want at least 100ms", name, dependWait)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package taskset
+
+import (
+	"context"
+	"errors"
+)
+
+type dependenciesProperty struct{}
+
+// WithDependencies declares the task's dependencies up front, for use by Plan and DryRun.
+// Tasks may still depend on other tasks, but Plan won't know about it.
+// Applying WithDependencies several times adds up the dependencies.
+func WithDependencies(dependencies ...*Task) Property {
+	return func(task *Task) {
+		task.ModifyProperty(dependenciesProperty{}, func(value interface{}) interface{} {
+			deps, _ := value.([]*Task)
+			return append(deps[:len(deps):len(deps)], dependencies...)
+		})
+	}
+}
+
+// Dependencies returns the dependencies declared for this task with WithDependencies.
+//
+// This method should only be used by Middlewares.
+func (t *Task) Dependencies() []*Task {
+	dependencies, _ := t.Property(dependenciesProperty{}).([]*Task)
+	return append([]*Task(nil), dependencies...)
+}
+
+// ErrCycle is returned by Plan and DryRun if the declared dependencies form a cycle.
+var ErrCycle = errors.New("dependency cycle")
+
+// ErrForeignDependency is returned by Plan and DryRun if a task declares a dependency
+// on a task it can't depend on, e.g. one from a different TaskSet.
+var ErrForeignDependency = errors.New("dependency is from a different task set")
+
+// PlannedTask is a task that will run when its TaskSet is started.
+type PlannedTask struct {
+	Task *Task
+	// Lazy is true if the task will only run because another planned task depends on it.
+	Lazy bool
+	// Dependencies are the tasks that the task depends on, declared with WithDependencies.
+	// For tasks created by NewSub, they also include the child TaskSet's tasks.
+	Dependencies []*Task
+	// Properties is a copy of the task's properties.
+	Properties map[interface{}]interface{}
+}
+
+// Plan returns every task that will run when this TaskSet is started: the non-lazy tasks
+// and the lazy tasks reachable from them through the dependencies declared with WithDependencies.
+// Tasks of child TaskSets created with NewSub are included as well.
+//
+// The tasks are returned in topological order, so that every task comes after its dependencies.
+// If the dependencies form a cycle, Plan returns ErrCycle. If a task declares a dependency
+// that it couldn't depend on with depend(), Plan returns ErrForeignDependency.
+func (ts *TaskSet) Plan() ([]PlannedTask, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var plan []PlannedTask
+	state := make(map[*Task]int)
+
+	var visit func(task *Task) error
+	visit = func(task *Task) error {
+		switch state[task] {
+		case visiting:
+			return ErrCycle
+		case visited:
+			return nil
+		}
+		state[task] = visiting
+
+		for _, dependency := range task.Dependencies() {
+			if !task.taskSet.descendantOf(dependency.taskSet) {
+				return ErrForeignDependency
+			}
+		}
+
+		dependencies := task.plannedDependencies()
+		for _, dependency := range dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+
+		state[task] = visited
+		plan = append(plan, PlannedTask{
+			Task:         task,
+			Lazy:         task.Lazy(),
+			Dependencies: dependencies,
+			Properties:   task.Properties(),
+		})
+		return nil
+	}
+
+	for _, task := range ts.eagerTasks {
+		if err := visit(task); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+type dryRunKey struct{}
+
+// IsDryRun reports whether ctx was passed to a middleware by DryRun.
+// Middlewares with side effects, such as saving results, should do nothing in a dry run.
+func IsDryRun(ctx context.Context) bool {
+	return ctx.Value(dryRunKey{}) != nil
+}
+
+// DryRun is like Start followed by Wait, except it walks the tasks returned by Plan in order,
+// and calls the middlewares for each of them as if they were run, with next() stubbed out.
+// No RunFunc is called, so the TaskSet can still be started afterwards.
+//
+// Start middlewares are called as by Start, with a next() that walks the tasks. For each task,
+// Run middlewares are called with a next() that calls Depend middlewares for each of the task's
+// planned dependencies, and returns an empty Result. The Depend middlewares get the Result
+// returned by the dependency's Run middlewares.
+//
+// Context passed to middlewares can be recognized with IsDryRun.
+func (ts *TaskSet) DryRun(ctx context.Context) error {
+	plan, err := ts.Plan()
+	if err != nil {
+		return err
+	}
+
+	ctx = context.WithValue(ctx, dryRunKey{}, struct{}{})
+	ctx = context.WithValue(ctx, ts, struct{}{})
+
+	ts.startMiddlewares(ctx, func(ctx context.Context) {
+		results := make(map[*Task]Result, len(plan))
+		for _, p := range plan {
+			task, dependencies := p.Task, p.Dependencies
+			results[task] = runMiddlewares(ctx, task, task.middlewares(), func(ctx context.Context) Result {
+				for _, dependency := range dependencies {
+					dependMiddlewares(ctx, task, dependency, task.middlewares(), func(ctx context.Context) Result {
+						return results[dependency]
+					})
+				}
+				return Result{}
+			})
+		}
+	})
+
+	return nil
+}
+
+func (ts *TaskSet) isEager(task *Task) bool {
+	for _, t := range ts.eagerTasks {
+		if t == task {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Task) plannedDependencies() []*Task {
+	dependencies, _ := t.Property(dependenciesProperty{}).([]*Task)
+	dependencies = dependencies[:len(dependencies):len(dependencies)]
+
+	if t.child != nil {
+		dependencies = append(dependencies, t.child.eagerTasks...)
+		if !t.child.isEager(t.child.result) {
+			dependencies = append(dependencies, t.child.result)
+		}
+	}
+
+	return dependencies
+}
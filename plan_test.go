@@ -0,0 +1,149 @@
+package taskset_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+)
+
+func ExampleTaskSet_Plan() {
+	taskSet := taskset.NewTaskSet()
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 2, nil
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value.(int) + 1, nil
+	},
+		properties.WithName("C"),
+		taskset.WithDependencies(taskA),
+	)
+
+	plan, err := taskSet.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, p := range plan {
+		fmt.Println(properties.Name(p.Task), "lazy:", p.Lazy)
+	}
+
+	// Output:
+	// A lazy: true
+	// C lazy: false
+}
+
+func ExampleTaskSet_DryRun() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet(
+		NewPrinter(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		panic("not called in a dry run")
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		panic("not called in a dry run")
+	},
+		properties.WithName("B"),
+		taskset.WithDependencies(taskA),
+	)
+
+	if err := taskSet.DryRun(ctx); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// A starting
+	// A finished
+	// B starting
+	// B depend on A starting
+	// B depend on A finished
+	// B finished
+}
+
+func TestTaskSet_Plan_foreignDependency(t *testing.T) {
+	other := taskset.NewTaskSet()
+	foreign := other.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	})
+
+	taskSet := taskset.NewTaskSet()
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		taskset.WithDependencies(foreign),
+	)
+
+	if _, err := taskSet.Plan(); !errors.Is(err, taskset.ErrForeignDependency) {
+		t.Errorf("Plan() = %v, want %v", err, taskset.ErrForeignDependency)
+	}
+	if err := taskSet.DryRun(context.Background()); !errors.Is(err, taskset.ErrForeignDependency) {
+		t.Errorf("DryRun() = %v, want %v", err, taskset.ErrForeignDependency)
+	}
+}
+
+func TestTaskSet_DryRun_start(t *testing.T) {
+	var events []string
+	taskSet := taskset.NewTaskSet(taskset.Middleware{
+		Start: func(ctx context.Context, taskSet *taskset.TaskSet, next func(ctx context.Context)) {
+			events = append(events, fmt.Sprintf("start, dry run: %v", taskset.IsDryRun(ctx)))
+			next(ctx)
+			events = append(events, "finish")
+		},
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			events = append(events, "run "+properties.Name(task))
+			return next(ctx)
+		},
+	})
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		panic("not called in a dry run")
+	},
+		properties.WithName("A"),
+	)
+
+	if err := taskSet.DryRun(context.Background()); err != nil {
+		t.Fatalf("DryRun() = %v", err)
+	}
+
+	want := []string{"start, dry run: true", "run A", "finish"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestTaskSet_Plan_subDependency(t *testing.T) {
+	taskSet := taskset.NewTaskSet()
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	})
+	taskSet.NewSub(func(child *taskset.TaskSet) *taskset.Task {
+		// A child's task may depend on its parent's tasks.
+		return child.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return depend(ctx, taskA).Value, nil
+		},
+			taskset.WithDependencies(taskA),
+		)
+	})
+
+	if _, err := taskSet.Plan(); err != nil {
+		t.Errorf("Plan() = %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package properties
+
+import "github.com/bennydictor/taskset"
+
+type groupProperty struct{}
+
+// WithGroup adds a task to a group, e.g. of tasks calling the same upstream.
+// Used by middlewares that treat groups of tasks together, like rate limiting.
+func WithGroup(group string) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(groupProperty{}, func(_ interface{}) interface{} {
+			return group
+		})
+	}
+}
+
+// Group gets the task's group added by WithGroup. If no group is found,
+// returns an empty string. This function should only be used by middlewares.
+func Group(task *taskset.Task) string {
+	group := task.Property(groupProperty{})
+	if group == nil {
+		return ""
+	}
+
+	return group.(string)
+}
@@ -0,0 +1,26 @@
+package properties
+
+import "github.com/bennydictor/taskset"
+
+type tenantProperty struct{}
+
+// WithTenant marks a task as done on behalf of a tenant, e.g. a customer.
+// Used by middlewares that share resources between tenants, like fair scheduling.
+func WithTenant(tenant string) taskset.Property {
+	return func(task *taskset.Task) {
+		task.ModifyProperty(tenantProperty{}, func(_ interface{}) interface{} {
+			return tenant
+		})
+	}
+}
+
+// Tenant gets the task's tenant added by WithTenant. If no tenant is found,
+// returns an empty string. This function should only be used by middlewares.
+func Tenant(task *taskset.Task) string {
+	tenant := task.Property(tenantProperty{})
+	if tenant == nil {
+		return ""
+	}
+
+	return tenant.(string)
+}
@@ -13,6 +13,8 @@ import (
 // Tasks are created by a TaskSet using a RunFunc and Properties.
 type Task struct {
 	taskSet *TaskSet
+	index   int
+	child   *TaskSet
 
 	propertiesMu sync.Mutex
 	properties   map[interface{}]interface{}
@@ -46,6 +48,7 @@ type RunFunc func(context.Context, Depend) (interface{}, error)
 func newTask(taskSet *TaskSet, run RunFunc) *Task {
 	return &Task{
 		taskSet:    taskSet,
+		index:      len(taskSet.tasks),
 		properties: make(map[interface{}]interface{}),
 		done:       make(chan struct{}),
 		run:        run,
@@ -53,11 +56,15 @@ func newTask(taskSet *TaskSet, run RunFunc) *Task {
 }
 
 func (t *Task) dependFunc(ctx context.Context, dependency *Task) Result {
-	if t.taskSet != dependency.taskSet {
+	if !t.taskSet.descendantOf(dependency.taskSet) {
 		panic("dependency is from a different task set")
 	}
 
-	return t.taskSet.middleware.Depend(ctx, t, dependency, func(ctx context.Context) Result {
+	return t.dependOn(ctx, dependency)
+}
+
+func (t *Task) dependOn(ctx context.Context, dependency *Task) Result {
+	return dependMiddlewares(ctx, t, dependency, t.middlewares(), func(ctx context.Context) Result {
 		return dependency.depend(ctx)
 	})
 }
@@ -67,7 +74,7 @@ func (t *Task) depend(ctx context.Context) Result {
 		go func() {
 			defer close(t.done)
 
-			t.result = t.taskSet.middleware.Run(ctx, t, func(ctx context.Context) (result Result) {
+			t.result = runMiddlewares(ctx, t, t.middlewares(), func(ctx context.Context) (result Result) {
 				result.Value, result.Err = t.run(ctx, t.dependFunc)
 				return
 			})
@@ -77,6 +84,14 @@ func (t *Task) depend(ctx context.Context) Result {
 	return t.wait(ctx)
 }
 
+// middlewares returns the task set's middlewares followed by
+// the middlewares added to this task by WithMiddleware.
+func (t *Task) middlewares() []Middleware {
+	mws, _ := t.Property(middlewareProperty{}).([]Middleware)
+	setMiddlewares := t.taskSet.middlewares
+	return append(setMiddlewares[:len(setMiddlewares):len(setMiddlewares)], mws...)
+}
+
 func (t *Task) wait(ctx context.Context) Result {
 	select {
 	case <-ctx.Done():
@@ -86,6 +101,72 @@ func (t *Task) wait(ctx context.Context) Result {
 	}
 }
 
+// Depend declares a dependency of this task on another task,
+// like the Depend function passed to the task's RunFunc.
+//
+// This method should only be used by Middlewares, during Run of this task, with
+// the context passed to Run. Only the Depend of the calling middleware and the ones
+// outside it are called, since the Run of the middlewares inside it hasn't started yet.
+func (t *Task) Depend(ctx context.Context, dependency *Task) Result {
+	if !t.taskSet.descendantOf(dependency.taskSet) {
+		panic("dependency is from a different task set")
+	}
+
+	middlewares := t.middlewares()
+	if entered, ok := ctx.Value(enteredKey{t}).(int); ok {
+		middlewares = middlewares[:entered]
+	}
+
+	return dependMiddlewares(ctx, t, dependency, middlewares, func(ctx context.Context) Result {
+		return dependency.depend(ctx)
+	})
+}
+
+// Await runs the dependency if it hasn't started yet, and waits for its result, like Depend,
+// except that no Depend middlewares are called. Use it in middlewares that need a result
+// of another task without declaring a dependency on it, so that the task's own depend()
+// call on the same dependency is the only one the other middlewares see.
+//
+// This method should only be used by Middlewares, during Run of this task, with
+// the context passed to Run.
+func (t *Task) Await(ctx context.Context, dependency *Task) Result {
+	if !t.taskSet.descendantOf(dependency.taskSet) {
+		panic("dependency is from a different task set")
+	}
+
+	return dependency.depend(ctx)
+}
+
+// Parent returns the Task created by TaskSet.NewSub that runs this task's TaskSet.
+// If this task's TaskSet wasn't created by NewSub, Parent returns nil.
+//
+// This method should only be used by Middlewares.
+func (t *Task) Parent() *Task {
+	return t.taskSet.parent
+}
+
+// TaskSet returns the TaskSet that created this task.
+//
+// This method should only be used by Middlewares.
+func (t *Task) TaskSet() *TaskSet {
+	return t.taskSet
+}
+
+// Index returns the number of tasks created by this task's TaskSet before this one.
+// Tasks of a child TaskSet created by NewSub are numbered separately, starting from 0.
+//
+// This method should only be used by Middlewares.
+func (t *Task) Index() int {
+	return t.index
+}
+
+// Lazy reports whether this task was created with NewLazy and wasn't marked with Eager since.
+//
+// This method should only be used by Middlewares.
+func (t *Task) Lazy() bool {
+	return !t.taskSet.isEager(t)
+}
+
 // Property retrieves this task's property by the given key.
 // If there's no property for the given key, nil is returned.
 //
@@ -105,6 +186,20 @@ func (t *Task) Property(key interface{}) interface{} {
 	return t.properties[key]
 }
 
+// Properties returns a copy of all of this task's properties.
+//
+// This method should only be used by Middlewares.
+func (t *Task) Properties() map[interface{}]interface{} {
+	t.propertiesMu.Lock()
+	defer t.propertiesMu.Unlock()
+
+	properties := make(map[interface{}]interface{}, len(t.properties))
+	for k, v := range t.properties {
+		properties[k] = v
+	}
+	return properties
+}
+
 // ModifyProperty modifies a property for this task.
 // The modify function runs under a mutex, to allow for serializable transactions.
 // Because of that, you shouldn't do any long operations in the modify function.
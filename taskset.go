@@ -2,25 +2,40 @@ package taskset
 
 import (
 	"context"
+	"sync"
 )
 
 // TaskSet creates and runs Tasks.
 type TaskSet struct {
-	middleware Middleware
+	name        string
+	middlewares []Middleware
+	parent      *Task
+	result      *Task
 
+	tasks      []*Task
 	eagerTasks []*Task
+
+	startedMu sync.Mutex
+	// started is closed once the Start middlewares return.
+	// It's created by the first call to Start or Wait, see startedC.
+	started chan struct{}
 }
 
 // NewTaskSet creates a new TaskSet.
 func NewTaskSet(middlewares ...Middleware) *TaskSet {
-	mw := chainMiddlewares(middlewares)
-	if mw.Run == nil {
-		mw.Run = func(ctx context.Context, _ *Task, next func(ctx context.Context) Result) Result { return next(ctx) }
-	}
-	if mw.Depend == nil {
-		mw.Depend = func(ctx context.Context, _, _ *Task, next func(ctx context.Context) Result) Result { return next(ctx) }
-	}
-	return &TaskSet{middleware: mw}
+	return &TaskSet{middlewares: middlewares}
+}
+
+// NewNamedTaskSet creates a new TaskSet with the given name.
+// The name isn't used by the TaskSet itself, but is available to middlewares, see Name.
+func NewNamedTaskSet(name string, middlewares ...Middleware) *TaskSet {
+	return &TaskSet{name: name, middlewares: middlewares}
+}
+
+// Name returns the name the TaskSet was created with by NewNamedTaskSet.
+// Child TaskSets created by NewSub have the same name as their parent.
+func (ts *TaskSet) Name() string {
+	return ts.name
 }
 
 // New creates a new Task given its RunFunc and Properties.
@@ -41,12 +56,49 @@ func (ts *TaskSet) New(run RunFunc, properties ...Property) *Task {
 // A lazy task can be later converted to a non-lazy with Eager.
 func (ts *TaskSet) NewLazy(run RunFunc, properties ...Property) *Task {
 	task := newTask(ts, run)
+	ts.tasks = append(ts.tasks, task)
 	for _, p := range properties {
 		p(task)
 	}
 	return task
 }
 
+// NewSub creates a new Task that runs a child TaskSet.
+//
+// The child TaskSet is created with the same middlewares as this one, and passed to build,
+// which must create the child's tasks and return the one whose Result becomes the Result
+// of the created Task. The child's tasks are run with the context of the created Task, and
+// all its non-lazy tasks are waited for before the created Task finishes.
+//
+// Tasks of the child TaskSet may depend on tasks of this TaskSet, but not the other way around.
+// From middlewares, the created Task can be found with Task.Parent of any child's task.
+func (ts *TaskSet) NewSub(build func(child *TaskSet) *Task, properties ...Property) *Task {
+	var task *Task
+
+	child := &TaskSet{name: ts.name, middlewares: ts.middlewares}
+	resultTask := build(child)
+	if resultTask.taskSet != child {
+		panic("task doesn't belong to task set")
+	}
+	child.result = resultTask
+
+	task = ts.New(func(ctx context.Context, _ Depend) (interface{}, error) {
+		ctx = context.WithValue(ctx, child, struct{}{})
+		depend := Depend(task.dependOn)
+
+		if len(child.eagerTasks) != 0 {
+			depend.SyncGroup(ctx, child.eagerTasks...)
+		}
+
+		result := depend(ctx, resultTask)
+		return result.Value, result.Err
+	}, properties...)
+	task.child = child
+	child.parent = task
+
+	return task
+}
+
 // Eager marks a lazy task to be non-lazy. The provided task must belong to this TaskSet.
 func (ts *TaskSet) Eager(task *Task) {
 	if task.taskSet != ts {
@@ -56,26 +108,70 @@ func (ts *TaskSet) Eager(task *Task) {
 	ts.eagerTasks = append(ts.eagerTasks, task)
 }
 
+// Tasks returns all tasks created by this TaskSet, in the order they were created.
+// It doesn't include the tasks of child task sets created by NewSub.
+func (ts *TaskSet) Tasks() []*Task {
+	return append([]*Task(nil), ts.tasks...)
+}
+
 // Start runs all non-lazy Tasks created by this task set.
 // Context will be passed to all the tasks' run functions.
 func (ts *TaskSet) Start(ctx context.Context) {
 	ctx = context.WithValue(ctx, ts, struct{}{})
 
-	for _, task := range ts.eagerTasks {
-		task := task
-		go func() { _ = task.depend(ctx) }()
+	started := ts.startedC()
+
+	go func() {
+		defer close(started)
+
+		ts.startMiddlewares(ctx, func(ctx context.Context) {
+			for _, task := range ts.eagerTasks {
+				task := task
+				go func() { _ = task.depend(ctx) }()
+			}
+
+			for _, task := range ts.eagerTasks {
+				<-task.done
+			}
+		})
+	}()
+}
+
+// startMiddlewares calls the Start middlewares with the given next.
+func (ts *TaskSet) startMiddlewares(ctx context.Context, next func(ctx context.Context)) {
+	start := chainMiddlewares(ts.middlewares).Start
+	if start == nil {
+		next(ctx)
+		return
 	}
+	start(ctx, ts, next)
 }
 
-// Wait waits for all non-lazy tasks to complete.
+// Wait waits for all non-lazy tasks to complete, and for the Start middlewares to return.
 // Context is only used to cancel Wait, it is not passed to any of the tasks' RunFuncs.
 //
-// Wait does not run any tasks, it only waits for them to finish. If you call Wait
-// and never call Start, it will block forever.
+// Wait does not run any tasks, it only waits for them to finish. It may be called
+// before or concurrently with Start. If you call Wait and never call Start, it will block forever.
 func (ts *TaskSet) Wait(ctx context.Context) {
 	for _, task := range ts.eagerTasks {
 		task.wait(ctx)
 	}
+
+	select {
+	case <-ts.startedC():
+	case <-ctx.Done():
+	}
+}
+
+// startedC returns the channel that Start closes once the Start middlewares return.
+func (ts *TaskSet) startedC() chan struct{} {
+	ts.startedMu.Lock()
+	defer ts.startedMu.Unlock()
+
+	if ts.started == nil {
+		ts.started = make(chan struct{})
+	}
+	return ts.started
 }
 
 // WaitC is a convenience method. It returns a channel
@@ -103,9 +199,35 @@ func (ts *TaskSet) Result(ctx context.Context, task *Task) Result {
 		panic("task doesn't belong to task set")
 	}
 
-	if ctx.Value(ts) != nil {
+	if ts.runningIn(ctx) {
 		panic("Don't call taskSet.Result(task) from inside tasks. Use depend(task) instead.")
 	}
 
 	return task.wait(ctx)
 }
+
+// descendantOf reports whether ts is other, or was created by NewSub of other or of its descendants.
+func (ts *TaskSet) descendantOf(other *TaskSet) bool {
+	for {
+		if ts == other {
+			return true
+		}
+		if ts.parent == nil {
+			return false
+		}
+		ts = ts.parent.taskSet
+	}
+}
+
+// runningIn reports whether ctx was passed to a task of ts or of any of its ancestors.
+func (ts *TaskSet) runningIn(ctx context.Context) bool {
+	for {
+		if ctx.Value(ts) != nil {
+			return true
+		}
+		if ts.parent == nil {
+			return false
+		}
+		ts = ts.parent.taskSet
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"testing"
 	"time"
 
 	"github.com/bennydictor/taskset"
@@ -101,3 +102,73 @@ func ExampleTaskSet_NewLazy() {
 
 	// Output: A, B, D
 }
+
+func ExampleTaskSet_NewSub() {
+	ctx := context.Background()
+
+	taskSet := taskset.NewTaskSet()
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, nil
+	})
+
+	taskB := taskSet.NewSub(func(child *taskset.TaskSet) *taskset.Task {
+		taskX := child.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			a := depend(ctx, taskA).Value.(int)
+			return a + 1, nil
+		})
+
+		return child.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			x := depend(ctx, taskX).Value.(int)
+			return x * 10, nil
+		})
+	})
+
+	taskSet.Start(ctx)
+	taskSet.Wait(ctx)
+
+	fmt.Println("result:", taskSet.Result(ctx, taskB).Value)
+
+	// Output: result: 20
+}
+
+func TestTaskSet_Wait_duringStart(t *testing.T) {
+	ctx := context.Background()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	taskSet := taskset.NewTaskSet(taskset.Middleware{
+		Start: func(ctx context.Context, taskSet *taskset.TaskSet, next func(ctx context.Context)) {
+			close(entered)
+			<-release
+			next(ctx)
+		},
+	})
+
+	// Wait may be called before Start, and concurrently with it.
+	// The task set has no tasks, so only Start keeps Wait blocked.
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	taskSet.Wait(waitCtx)
+	if waitCtx.Err() == nil {
+		t.Fatal("Wait called before Start returned before Start was called")
+	}
+
+	before := taskSet.WaitC()
+	taskSet.Start(ctx)
+	<-entered
+	during := taskSet.WaitC()
+
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-before:
+		t.Fatal("Wait called before Start returned before the Start middleware")
+	case <-during:
+		t.Fatal("Wait called during Start returned before the Start middleware")
+	default:
+	}
+
+	close(release)
+	<-before
+	<-during
+}
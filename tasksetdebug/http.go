@@ -0,0 +1,112 @@
+package tasksetdebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+func init() {
+	http.HandleFunc("/debug/taskset/", Index)
+	http.HandleFunc("/debug/taskset/graph", Graph)
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>/debug/taskset/</title>
+<style>
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+.failed { color: red; }
+.succeeded { color: green; }
+.waiting, .pending { color: gray; }
+</style>
+</head>
+<body>
+<h1>/debug/taskset/</h1>
+<p>{{len .}} live task sets. <a href="?format=json">JSON</a></p>
+{{range .}}
+<h2>#{{.ID}} {{.Name}}</h2>
+<p>
+Created {{.Created.Format "2006-01-02 15:04:05.000"}}.
+Graph: <a href="graph?set={{.ID}}&format=dot">dot</a>, <a href="graph?set={{.ID}}&format=mermaid">mermaid</a>, <a href="graph?set={{.ID}}&format=json">json</a>
+</p>
+<table>
+<tr><th>ID</th><th>Name</th><th>State</th><th>Elapsed, s</th><th>Waiting on</th><th>Properties</th></tr>
+{{range .Tasks}}
+<tr>
+<td>{{.ID}}</td>
+<td>{{.Name}}{{if .Lazy}} (lazy){{end}}</td>
+<td class="{{.State}}">{{.State}}{{with .Error}}: {{.}}{{end}}</td>
+<td>{{printf "%.3f" .ElapsedSeconds}}</td>
+<td>{{range .WaitingOn}}{{.}} {{end}}</td>
+<td>{{range $k, $v := .Properties}}{{$k}}={{$v}}<br>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// Index responds with the state of all live Sets, as an HTML page,
+// or as a JSON array of SetInfo if the "format" query parameter is "json".
+func Index(w http.ResponseWriter, r *http.Request) {
+	sets := Sets()
+	infos := make([]SetInfo, 0, len(sets))
+	for _, s := range sets {
+		infos = append(infos, s.Info())
+	}
+
+	if r.FormValue("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(infos)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, infos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Graph responds with the dependency graph of the Set with ID given by the "set" query parameter.
+// The "format" query parameter is one of "dot" (the default), "mermaid" or "json".
+func Graph(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("set"))
+	if err != nil {
+		http.Error(w, "invalid set id", http.StatusBadRequest)
+		return
+	}
+
+	s := lookup(id)
+	if s == nil {
+		http.Error(w, fmt.Sprintf("no live set with id %d", id), http.StatusNotFound)
+		return
+	}
+
+	graph := s.Graph()
+	switch format := r.FormValue("format"); format {
+	case "", "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		err = graph.WriteDOT(w)
+	case "mermaid":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = graph.WriteMermaid(w)
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		err = graph.WriteJSON(w)
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
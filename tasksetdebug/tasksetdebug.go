@@ -0,0 +1,236 @@
+// Package tasksetdebug serves the state of running task sets via its HTTP server,
+// in the manner of net/http/pprof.
+//
+// The package is typically only imported for the side effect of registering its
+// HTTP handlers. The handled paths all begin with /debug/taskset/.
+//
+// To make a TaskSet visible, register it with New and use the returned Set's middleware:
+//
+//	debugSet := tasksetdebug.New("checkout")
+//	defer debugSet.Close()
+//	taskSet := taskset.NewTaskSet(debugSet.Middleware())
+//
+// Then visit /debug/taskset/ to list every live set and the state of its tasks.
+// The same information is available as JSON at /debug/taskset/?format=json, and the
+// dependency graph of each set at /debug/taskset/graph?set=ID&format=dot, with
+// format being one of "dot", "mermaid" or "json".
+package tasksetdebug
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+)
+
+var registry struct {
+	sync.Mutex
+	lastID int
+	sets   map[int]*Set
+}
+
+// Set is a TaskSet registered for debugging.
+type Set struct {
+	id      int
+	name    string
+	created time.Time
+
+	recorder middlewares.GraphRecorder
+
+	mu      sync.Mutex
+	waiting map[*taskset.Task]map[*taskset.Task]int
+}
+
+// New registers a new Set with the given name. The name doesn't have to be unique.
+// The Set is served until Close is called.
+func New(name string) *Set {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.lastID++
+	s := &Set{
+		id:      registry.lastID,
+		name:    name,
+		created: time.Now(),
+		waiting: make(map[*taskset.Task]map[*taskset.Task]int),
+	}
+
+	if registry.sets == nil {
+		registry.sets = make(map[int]*Set)
+	}
+	registry.sets[s.id] = s
+
+	return s
+}
+
+// Close unregisters the Set.
+func (s *Set) Close() {
+	registry.Lock()
+	defer registry.Unlock()
+
+	delete(registry.sets, s.id)
+}
+
+// Middleware provides the taskset.Middleware that records the state of the tasks.
+// It should be the first middleware of the TaskSet, so that elapsed times include
+// the time spent in other middlewares.
+func (s *Set) Middleware() taskset.Middleware {
+	recorder := s.recorder.Middleware()
+
+	return taskset.Middleware{
+		Run: recorder.Run,
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			s.mu.Lock()
+			if _, ok := s.waiting[task]; !ok {
+				s.waiting[task] = make(map[*taskset.Task]int)
+			}
+			s.waiting[task][dependency]++
+			s.mu.Unlock()
+
+			defer func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+
+				s.waiting[task][dependency]--
+				if s.waiting[task][dependency] == 0 {
+					delete(s.waiting[task], dependency)
+				}
+			}()
+
+			return recorder.Depend(ctx, task, dependency, next)
+		},
+	}
+}
+
+// Graph returns the dependency graph of the tasks recorded so far.
+func (s *Set) Graph() middlewares.Graph {
+	return s.recorder.Graph()
+}
+
+// TaskState is the state of a task.
+type TaskState string
+
+const (
+	// TaskPending is the state of a task that was depended on, but hasn't started running yet.
+	TaskPending = TaskState("pending")
+	// TaskRunning is the state of a task that is running its RunFunc.
+	TaskRunning = TaskState("running")
+	// TaskWaiting is the state of a task that is waiting in depend().
+	TaskWaiting = TaskState("waiting")
+	// TaskSucceeded is the state of a task that returned a successful Result.
+	TaskSucceeded = TaskState("succeeded")
+	// TaskFailed is the state of a task that returned a failed Result.
+	TaskFailed = TaskState("failed")
+)
+
+// SetInfo describes a Set.
+type SetInfo struct {
+	ID      int        `json:"id"`
+	Name    string     `json:"name"`
+	Created time.Time  `json:"created"`
+	Tasks   []TaskInfo `json:"tasks"`
+}
+
+// TaskInfo describes a task of a Set.
+type TaskInfo struct {
+	// ID is middlewares.GraphNode.ID of the task.
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	Lazy  bool      `json:"lazy"`
+	State TaskState `json:"state"`
+	Error string    `json:"error,omitempty"`
+	// ElapsedSeconds is the time the task has been running for, or ran for if it's finished.
+	// It's zero if the task is pending.
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	// WaitingOn are the IDs of the tasks this task is waiting for in depend().
+	WaitingOn []string `json:"waiting_on,omitempty"`
+	// Properties are the task's properties, as returned by middlewares.GraphNode.PropertyStrings.
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// Info returns the current state of the Set.
+func (s *Set) Info() SetInfo {
+	graph := s.Graph()
+	now := time.Now()
+
+	info := SetInfo{
+		ID:      s.id,
+		Name:    s.name,
+		Created: s.created,
+		Tasks:   make([]TaskInfo, 0, len(graph.Nodes)),
+	}
+
+	ids := make(map[*taskset.Task]string, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		ids[n.Task] = n.ID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range graph.Nodes {
+		task := TaskInfo{
+			ID:         n.ID,
+			Name:       n.Label(),
+			Lazy:       n.Lazy,
+			Properties: n.PropertyStrings(),
+		}
+
+		for dependency := range s.waiting[n.Task] {
+			id, ok := ids[dependency]
+			if !ok {
+				// The dependency hasn't been recorded yet, it's just being started.
+				id = "?"
+			}
+			task.WaitingOn = append(task.WaitingOn, id)
+		}
+		sort.Strings(task.WaitingOn)
+
+		switch {
+		case n.Status == middlewares.TaskSucceeded:
+			task.State = TaskSucceeded
+			task.ElapsedSeconds = n.Duration().Seconds()
+		case n.Status == middlewares.TaskFailed:
+			task.State = TaskFailed
+			task.Error = n.Err.Error()
+			task.ElapsedSeconds = n.Duration().Seconds()
+		case n.Start.IsZero():
+			task.State = TaskPending
+		case len(task.WaitingOn) != 0:
+			task.State = TaskWaiting
+			task.ElapsedSeconds = now.Sub(n.Start).Seconds()
+		default:
+			task.State = TaskRunning
+			task.ElapsedSeconds = now.Sub(n.Start).Seconds()
+		}
+
+		info.Tasks = append(info.Tasks, task)
+	}
+
+	return info
+}
+
+// Sets returns all live Sets, ordered by the time they were created.
+func Sets() []*Set {
+	registry.Lock()
+	defer registry.Unlock()
+
+	sets := make([]*Set, 0, len(registry.sets))
+	for _, s := range registry.sets {
+		sets = append(sets, s)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].id < sets[j].id
+	})
+	return sets
+}
+
+func lookup(id int) *Set {
+	registry.Lock()
+	defer registry.Unlock()
+
+	return registry.sets[id]
+}
@@ -0,0 +1,127 @@
+package tasksetdebug_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+	"github.com/bennydictor/taskset/tasksetdebug"
+)
+
+func ExampleIndex() {
+	ctx := context.Background()
+
+	debugSet := tasksetdebug.New("example")
+	defer debugSet.Close()
+
+	taskSet := taskset.NewTaskSet(
+		debugSet.Middleware(),
+	)
+
+	running, unblock := make(chan struct{}), make(chan struct{})
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		close(running)
+		<-unblock
+		return 1, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, taskA).Value.(int) + 1, nil
+	},
+		properties.WithName("B"),
+	)
+
+	// B is waiting for A by the time A runs.
+	taskSet.Start(ctx)
+	<-running
+
+	response := httptest.NewRecorder()
+	tasksetdebug.Index(response, httptest.NewRequest("GET", "/debug/taskset/?format=json", nil))
+
+	var sets []tasksetdebug.SetInfo
+	if err := json.Unmarshal(response.Body.Bytes(), &sets); err != nil {
+		panic(err)
+	}
+
+	for _, s := range sets {
+		fmt.Println(s.Name)
+		for _, t := range s.Tasks {
+			fmt.Println("   ", t.ID, t.Name, t.State, t.WaitingOn)
+		}
+	}
+
+	close(unblock)
+	taskSet.Wait(ctx)
+
+	// Output:
+	// example
+	//     task_0 A running []
+	//     task_1 B waiting [task_0]
+}
+
+func TestSet_Info_pending(t *testing.T) {
+	ctx := context.Background()
+
+	debugSet := tasksetdebug.New("pending")
+	defer debugSet.Close()
+
+	// A is held back before it reaches the debug middleware.
+	unblock := make(chan struct{})
+	hold := taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			if properties.Name(task) == "A" {
+				<-unblock
+			}
+			return next(ctx)
+		},
+	}
+
+	taskSet := taskset.NewTaskSet(
+		hold,
+		debugSet.Middleware(),
+	)
+
+	taskA := taskSet.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return nil, nil
+	},
+		properties.WithName("A"),
+	)
+
+	taskB := taskSet.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		return nil, depend(cancelled, taskA).Err
+	},
+		properties.WithName("B"),
+	)
+
+	taskSet.Start(ctx)
+	taskSet.Result(ctx, taskB)
+
+	found := false
+	for _, task := range debugSet.Info().Tasks {
+		if task.Name != "A" {
+			continue
+		}
+		found = true
+		if task.State != tasksetdebug.TaskPending {
+			t.Errorf("A state = %v, want %v", task.State, tasksetdebug.TaskPending)
+		}
+		if task.ElapsedSeconds != 0 {
+			t.Errorf("A elapsed = %vs, want 0", task.ElapsedSeconds)
+		}
+	}
+	if !found {
+		t.Errorf("A isn't listed")
+	}
+
+	close(unblock)
+	taskSet.Wait(ctx)
+}
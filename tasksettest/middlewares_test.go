@@ -0,0 +1,313 @@
+package tasksettest_test
+
+// Tests of the timing-dependent middlewares from the middlewares package live here,
+// rather than next to the middlewares, because they need the virtual clock of
+// testing/synctest, which the root module's Go version doesn't have.
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/middlewares"
+	"github.com/bennydictor/taskset/properties"
+	"github.com/bennydictor/taskset/tasksettest"
+)
+
+func TestCriticalPath(t *testing.T) {
+	tasksettest.Test(t, 0, func(t *testing.T, s *tasksettest.Scheduler) {
+		ctx := context.Background()
+
+		criticalPath := middlewares.NewCriticalPath()
+		ts := taskset.NewTaskSet(
+			s.Middleware(),
+			criticalPath.Middleware(),
+		)
+
+		a := ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return 1, s.Sleep(ctx, 200*time.Millisecond)
+		}, properties.WithName("A"))
+
+		b := ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return 2, s.Sleep(ctx, 500*time.Millisecond)
+		}, properties.WithName("B"))
+
+		c := ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			depend.SyncGroup(ctx, a, b)
+			return nil, s.Sleep(ctx, 100*time.Millisecond)
+		}, properties.WithName("C"))
+
+		ts.Start(ctx)
+		s.Run()
+
+		report := criticalPath.Report()
+
+		var path []*taskset.Task
+		for _, task := range report.Path {
+			path = append(path, task.Task)
+		}
+		if len(path) != 2 || path[0] != b || path[1] != c {
+			t.Errorf("critical path = %v, want [B C]", names(path))
+		}
+		if report.Total != 600*time.Millisecond {
+			t.Errorf("total = %v, want 600ms", report.Total)
+		}
+		if slack := report.Slack[a]; slack != 300*time.Millisecond {
+			t.Errorf("A slack = %v, want 300ms", slack)
+		}
+	})
+}
+
+func names(tasks []*taskset.Task) []string {
+	var names []string
+	for _, task := range tasks {
+		names = append(names, properties.Name(task))
+	}
+	return names
+}
+
+func TestDependTimer(t *testing.T) {
+	tasksettest.Test(t, 0, func(t *testing.T, s *tasksettest.Scheduler) {
+		var timer middlewares.DependTimer
+
+		// Two overlapping depend() calls, like the ones made by SyncGroup.
+		timer.Start()
+		time.Sleep(time.Second)
+		timer.Start()
+		time.Sleep(time.Second)
+		timer.Stop()
+		if total := timer.Total(); total != 0 {
+			t.Errorf("total while in depend() = %v, want 0", total)
+		}
+		time.Sleep(time.Second)
+		timer.Stop()
+
+		time.Sleep(time.Second)
+		timer.Start()
+		time.Sleep(time.Second)
+		timer.Stop()
+
+		if total := timer.Total(); total != 4*time.Second {
+			t.Errorf("total = %v, want 4s", total)
+		}
+	})
+}
+
+func TestNewLogger_stacked(t *testing.T) {
+	tasksettest.Test(t, 0, func(t *testing.T, s *tasksettest.Scheduler) {
+		ctx := context.Background()
+
+		newLogger := func(buf *bytes.Buffer) taskset.Middleware {
+			return middlewares.NewLogger(middlewares.LoggerOptions{
+				Logger:    log.New(buf, "", 0),
+				Durations: true,
+				// Only log the finished tasks.
+				FailuresOnly:  true,
+				SlowThreshold: time.Nanosecond,
+			})
+		}
+
+		var outer, inner bytes.Buffer
+		ts := taskset.NewTaskSet(s.Middleware(), newLogger(&outer), newLogger(&inner))
+
+		a := ts.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, s.Sleep(ctx, 300*time.Millisecond)
+		},
+			properties.WithName("A"),
+		)
+		ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			if err := depend(ctx, a).Err; err != nil {
+				return nil, err
+			}
+			return nil, s.Sleep(ctx, 100*time.Millisecond)
+		},
+			properties.WithName("B"),
+		)
+
+		ts.Start(ctx)
+		s.Run()
+
+		// Each logger excludes the time B spent waiting for A from B's self time.
+		want := "task A finished successfully in 300ms (self 300ms)\n" +
+			"task B finished successfully in 400ms (self 100ms)\n"
+		for name, buf := range map[string]*bytes.Buffer{"outer": &outer, "inner": &inner} {
+			if got := buf.String(); got != want {
+				t.Errorf("%s logger wrote:\n%s\nwant:\n%s", name, got, want)
+			}
+		}
+	})
+}
+
+func TestNewRateLimiter_order(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		limiterFirst bool
+		wantSelfTime time.Duration
+	}{
+		{name: "limiter first", limiterFirst: true, wantSelfTime: 100 * time.Millisecond},
+		{name: "limiter last", limiterFirst: false, wantSelfTime: 1100 * time.Millisecond},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tasksettest.Test(t, 0, func(t *testing.T, s *tasksettest.Scheduler) {
+				ctx := context.Background()
+
+				// The bucket is emptied, so that the task waits a second for the next token.
+				bucket := middlewares.NewTokenBucket(1, 1)
+				if err := bucket.Wait(ctx); err != nil {
+					t.Fatal(err)
+				}
+
+				limiter := middlewares.NewRateLimiter(map[string]*middlewares.TokenBucket{"": bucket})
+				criticalPath := middlewares.NewCriticalPath()
+
+				mws := []taskset.Middleware{s.Middleware(), limiter, criticalPath.Middleware()}
+				if !test.limiterFirst {
+					mws = []taskset.Middleware{s.Middleware(), criticalPath.Middleware(), limiter}
+				}
+				ts := taskset.NewTaskSet(mws...)
+
+				a := ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+					return nil, s.Sleep(ctx, 100*time.Millisecond)
+				}, properties.WithName("A"))
+
+				start := s.Now()
+				ts.Start(ctx)
+				s.Run()
+
+				if elapsed := s.Now().Sub(start); elapsed != 1100*time.Millisecond {
+					t.Errorf("elapsed = %v, want 1.1s", elapsed)
+				}
+
+				report := criticalPath.Report()
+				if len(report.Path) != 1 || report.Path[0].Task != a {
+					t.Fatalf("critical path = %v, want [A]", report.Path)
+				}
+				if self := report.Path[0].SelfTime; self != test.wantSelfTime {
+					t.Errorf("A self time = %v, want %v", self, test.wantSelfTime)
+				}
+			})
+		})
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	tasksettest.Test(t, 0, func(t *testing.T, s *tasksettest.Scheduler) {
+		ctx := context.Background()
+
+		limiter := middlewares.NewAdaptiveLimiter(middlewares.AdaptiveLimiterOptions{
+			InitialLimit: 2,
+		})
+		run := func(n int, d time.Duration) {
+			ts := taskset.NewTaskSet(
+				s.Middleware(),
+				limiter.Middleware(),
+			)
+			for i := 0; i < n; i++ {
+				ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+					return nil, s.Sleep(ctx, d)
+				})
+			}
+			ts.Start(ctx)
+			s.Run()
+		}
+
+		// The tasks take the same time regardless of concurrency,
+		// so the limit grows by 1/limit with each of them.
+		run(20, 20*time.Millisecond)
+		if limit := limiter.Limit(""); limit != 6 {
+			t.Errorf("limit after fast tasks = %v, want 6", limit)
+		}
+
+		// A task that takes more than twice the baseline signals overload.
+		run(1, 100*time.Millisecond)
+		if limit := limiter.Limit(""); limit != 5 {
+			t.Errorf("limit after a slow task = %v, want 5", limit)
+		}
+	})
+}
+
+// fairOrder runs a task that holds the only slot of limiter, then runs the tasks of tenants
+// while it does, and returns the tenants of the tasks in the order they got the slot.
+func fairOrder(t *testing.T, seed int64, limiter *middlewares.FairLimiter, tenants ...string) (order []string) {
+	tasksettest.Test(t, seed, func(t *testing.T, s *tasksettest.Scheduler) {
+		ctx := context.Background()
+
+		var mu sync.Mutex
+		record := func(tenant string) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, tenant)
+		}
+
+		blocker := taskset.NewTaskSet(s.Middleware(), limiter.Middleware())
+		blocker.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, s.Sleep(ctx, 100*time.Millisecond)
+		})
+		blocker.Start(ctx)
+		// The blocker takes the slot.
+		s.Step()
+
+		// Each tenant's tasks run in its own task set, like requests do.
+		for _, tenant := range tenants {
+			tenant := tenant
+			ts := taskset.NewTaskSet(s.Middleware(), limiter.TenantMiddleware(tenant))
+			ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+				record(tenant)
+				return nil, s.Sleep(ctx, 10*time.Millisecond)
+			})
+			ts.Start(ctx)
+		}
+
+		s.Run()
+	})
+	return order
+}
+
+func TestFairLimiter(t *testing.T) {
+	// The tenants take turns, until only one of them is waiting.
+	want := map[string][]string{
+		"big":   {"big", "small", "big", "small", "big", "big"},
+		"small": {"small", "big", "small", "big", "big", "big"},
+	}
+
+	for seed := int64(0); seed < 10; seed++ {
+		limiter := middlewares.NewFairLimiter(middlewares.FairLimiterOptions{
+			Slots: 1,
+		})
+
+		order := fairOrder(t, seed, limiter, "big", "big", "big", "big", "small", "small")
+		if len(order) == 0 || !reflect.DeepEqual(order, want[order[0]]) {
+			t.Errorf("seed %d: order = %v", seed, order)
+		}
+	}
+}
+
+func TestFairLimiter_weight(t *testing.T) {
+	// Premium tasks get twice as many turns.
+	want := map[string][]string{
+		"free":    {"free", "premium", "premium", "free", "premium", "premium", "free"},
+		"premium": {"premium", "premium", "free", "premium", "premium", "free", "free"},
+	}
+
+	for seed := int64(0); seed < 10; seed++ {
+		limiter := middlewares.NewFairLimiter(middlewares.FairLimiterOptions{
+			Slots: 1,
+			Weight: func(tenant string) int {
+				if tenant == "premium" {
+					return 2
+				}
+				return 1
+			},
+		})
+
+		order := fairOrder(t, seed, limiter, "free", "free", "free", "premium", "premium", "premium", "premium")
+		if len(order) == 0 || !reflect.DeepEqual(order, want[order[0]]) {
+			t.Errorf("seed %d: order = %v", seed, order)
+		}
+	}
+}
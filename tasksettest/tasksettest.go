@@ -0,0 +1,297 @@
+// Package tasksettest provides a deterministic scheduler for testing task sets.
+//
+// The scheduler runs inside a testing/synctest bubble, so time.Now, time.Sleep and
+// timers used by tasks run on a virtual clock. Tasks are single-stepped: at any moment
+// at most one task runs between two scheduling points, and the choice of which task
+// runs next is made by a pseudo-random generator seeded by the test, so a given
+// interleaving can be replayed by running the test with the same seed.
+package tasksettest
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/bennydictor/taskset"
+)
+
+// Test runs f in a new synctest bubble, passing it a new Scheduler with the given seed.
+// See testing/synctest.Test for the rules that apply to f.
+func Test(t *testing.T, seed int64, f func(t *testing.T, s *Scheduler)) {
+	t.Helper()
+	synctest.Test(t, func(t *testing.T) {
+		f(t, NewScheduler(seed))
+	})
+}
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	// TaskStarted is recorded when a task is given its first turn.
+	TaskStarted = EventKind(iota)
+	// TaskFinished is recorded when a task's Run middlewares return.
+	TaskFinished
+	// DependStarted is recorded when a task starts waiting for a dependency.
+	DependStarted
+	// DependFinished is recorded when a task resumes after waiting for a dependency.
+	DependFinished
+	// SleepFinished is recorded when a task resumes after Scheduler.Sleep.
+	SleepFinished
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case TaskStarted:
+		return "task started"
+	case TaskFinished:
+		return "task finished"
+	case DependStarted:
+		return "depend started"
+	case DependFinished:
+		return "depend finished"
+	case SleepFinished:
+		return "sleep finished"
+	default:
+		return "unknown event"
+	}
+}
+
+// Event is a single scheduling event recorded by a Scheduler.
+type Event struct {
+	// Step is the number of the Step call during which the event happened, starting from 1.
+	Step int
+	// Time is the virtual time of the event.
+	Time time.Time
+	Kind EventKind
+	Task *taskset.Task
+	// Dependency is only set for DependStarted and DependFinished events.
+	Dependency *taskset.Task
+}
+
+type waiter struct {
+	kind       EventKind
+	task       *taskset.Task
+	dependency *taskset.Task
+	turn       chan struct{}
+}
+
+// Scheduler is a deterministic scheduler for task sets. Use Scheduler.Middleware
+// to schedule a TaskSet, and Step or Run to drive it.
+//
+// A Scheduler must only be used inside a synctest bubble, for example one created by Test.
+type Scheduler struct {
+	mu      sync.Mutex
+	rand    *rand.Rand
+	step    int
+	running int
+	ready   []waiter
+	wake    chan struct{}
+	events  []Event
+}
+
+// NewScheduler creates a new Scheduler with the given seed.
+func NewScheduler(seed int64) *Scheduler {
+	return &Scheduler{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Middleware provides the taskset.Middleware. It should be the first middleware of
+// the TaskSet, so that other middlewares only run during the task's turn.
+//
+// Every task waits for its turn before it starts, and after each depend() call.
+func (s *Scheduler) Middleware() taskset.Middleware {
+	return taskset.Middleware{
+		Run: func(ctx context.Context, task *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			s.mu.Lock()
+			s.running++
+			s.mu.Unlock()
+
+			s.waitTurn(waiter{kind: TaskStarted, task: task})
+			result := next(context.WithValue(ctx, taskKey{}, task))
+
+			s.mu.Lock()
+			s.running--
+			s.record(TaskFinished, task, nil)
+			s.mu.Unlock()
+
+			return result
+		},
+		Depend: func(ctx context.Context, task, dependency *taskset.Task, next func(ctx context.Context) taskset.Result) taskset.Result {
+			s.mu.Lock()
+			s.record(DependStarted, task, dependency)
+			s.mu.Unlock()
+
+			result := next(ctx)
+
+			s.waitTurn(waiter{kind: DependFinished, task: task, dependency: dependency})
+			return result
+		},
+	}
+}
+
+type taskKey struct{}
+
+// Sleep pauses the task running with ctx for the duration d of virtual time, then
+// waits for its turn. Unlike time.Sleep, tasks that wake up at the same time don't run
+// concurrently. Sleep returns ctx.Err() if ctx is done before the duration elapses.
+func (s *Scheduler) Sleep(ctx context.Context, d time.Duration) error {
+	task, _ := ctx.Value(taskKey{}).(*taskset.Task)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	s.waitTurn(waiter{kind: SleepFinished, task: task})
+	return nil
+}
+
+// Now returns the current virtual time.
+func (s *Scheduler) Now() time.Time {
+	return time.Now()
+}
+
+func (s *Scheduler) waitTurn(w waiter) {
+	w.turn = make(chan struct{})
+
+	s.mu.Lock()
+	s.ready = append(s.ready, w)
+	if s.wake != nil {
+		close(s.wake)
+		s.wake = nil
+	}
+	s.mu.Unlock()
+
+	<-w.turn
+}
+
+// record must be called with s.mu held.
+func (s *Scheduler) record(kind EventKind, task, dependency *taskset.Task) {
+	s.events = append(s.events, Event{
+		Step:       s.step,
+		Time:       time.Now(),
+		Kind:       kind,
+		Task:       task,
+		Dependency: dependency,
+	})
+}
+
+// Step waits until every task is blocked, then gives the turn to one of the tasks waiting
+// for it, and waits until every task is blocked again. If no task is waiting for its turn,
+// but some tasks are sleeping, the virtual clock is advanced until one of them wakes up.
+//
+// Step returns false if there are no tasks left to run.
+// Step must not be called concurrently, and must be called from the synctest bubble.
+func (s *Scheduler) Step() bool {
+	for {
+		synctest.Wait()
+
+		s.mu.Lock()
+		if len(s.ready) != 0 {
+			w := s.pick()
+			s.step++
+			s.record(w.kind, w.task, w.dependency)
+			s.mu.Unlock()
+
+			close(w.turn)
+			synctest.Wait()
+			return true
+		}
+
+		if s.running == 0 {
+			s.mu.Unlock()
+			return false
+		}
+
+		wake := make(chan struct{})
+		s.wake = wake
+		s.mu.Unlock()
+
+		<-wake
+	}
+}
+
+// Run calls Step until there are no tasks left to run.
+func (s *Scheduler) Run() {
+	for s.Step() {
+	}
+}
+
+// pick must be called with s.mu held.
+func (s *Scheduler) pick() waiter {
+	// Tasks that became ready during the same step may have done so in any order,
+	// so sort them before choosing.
+	sort.Slice(s.ready, func(i, j int) bool {
+		return waiterLess(s.ready[i], s.ready[j])
+	})
+
+	i := s.rand.Intn(len(s.ready))
+	w := s.ready[i]
+	s.ready = append(s.ready[:i], s.ready[i+1:]...)
+	return w
+}
+
+func waiterLess(a, b waiter) bool {
+	if c := compareTasks(a.task, b.task); c != 0 {
+		return c < 0
+	}
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	return compareTasks(a.dependency, b.dependency) < 0
+}
+
+// compareTasks orders tasks by their position in the tree of task sets.
+func compareTasks(a, b *taskset.Task) int {
+	pa, pb := taskPath(a), taskPath(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] != pb[i] {
+			return pa[i] - pb[i]
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func taskPath(task *taskset.Task) []int {
+	if task == nil {
+		return nil
+	}
+	return append(taskPath(task.Parent()), task.Index())
+}
+
+// Events returns all events recorded so far, in the order they happened.
+func (s *Scheduler) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Event(nil), s.events...)
+}
+
+// HappenedBefore reports whether task a finished before task b did.
+// It returns false if either task hasn't finished.
+func (s *Scheduler) HappenedBefore(a, b *taskset.Task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aFinished, bFinished := -1, -1
+	for i, e := range s.events {
+		if e.Kind == TaskFinished && e.Task == a {
+			aFinished = i
+		}
+		if e.Kind == TaskFinished && e.Task == b {
+			bFinished = i
+		}
+	}
+
+	return aFinished != -1 && bFinished != -1 && aFinished < bFinished
+}
@@ -0,0 +1,116 @@
+package tasksettest_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/bennydictor/taskset"
+	"github.com/bennydictor/taskset/properties"
+	"github.com/bennydictor/taskset/tasksettest"
+)
+
+func newDiamond(s *tasksettest.Scheduler) (ts *taskset.TaskSet, a, b, c, d *taskset.Task) {
+	ts = taskset.NewTaskSet(s.Middleware())
+
+	a = ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return 1, s.Sleep(ctx, time.Second)
+	}, properties.WithName("A"))
+
+	b = ts.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, a).Value.(int) + 1, s.Sleep(ctx, time.Second)
+	}, properties.WithName("B"))
+
+	c = ts.NewLazy(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		return depend(ctx, a).Value.(int) + 2, s.Sleep(ctx, time.Second)
+	}, properties.WithName("C"))
+
+	d = ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+		if t := depend.ErrGroup(ctx, b, c); t != nil {
+			return nil, depend(ctx, t).Err
+		}
+		return depend(ctx, b).Value.(int) + depend(ctx, c).Value.(int), nil
+	}, properties.WithName("D"))
+
+	return
+}
+
+func TestScheduler_HappenedBefore(t *testing.T) {
+	tasksettest.Test(t, 1, func(t *testing.T, s *tasksettest.Scheduler) {
+		ctx := context.Background()
+		ts, a, b, c, d := newDiamond(s)
+
+		start := s.Now()
+		ts.Start(ctx)
+		s.Run()
+
+		if got := ts.Result(ctx, d).Value; got != 5 {
+			t.Errorf("result = %v, want 5", got)
+		}
+		if elapsed := s.Now().Sub(start); elapsed != 2*time.Second {
+			t.Errorf("elapsed = %v, want 2s", elapsed)
+		}
+
+		for _, edge := range [][2]*taskset.Task{{a, b}, {a, c}, {b, d}, {c, d}} {
+			if !s.HappenedBefore(edge[0], edge[1]) {
+				t.Errorf("%s didn't happen before %s", properties.Name(edge[0]), properties.Name(edge[1]))
+			}
+		}
+		if s.HappenedBefore(d, a) {
+			t.Errorf("D happened before A")
+		}
+	})
+}
+
+func TestScheduler_Replay(t *testing.T) {
+	run := func(seed int64) (order []string) {
+		tasksettest.Test(t, seed, func(t *testing.T, s *tasksettest.Scheduler) {
+			ts, _, _, _, _ := newDiamond(s)
+			ts.Start(context.Background())
+			s.Run()
+
+			for _, e := range s.Events() {
+				order = append(order, e.Kind.String()+" "+properties.Name(e.Task))
+			}
+		})
+		return
+	}
+
+	for seed := int64(0); seed < 10; seed++ {
+		first := run(seed)
+		for i := 0; i < 5; i++ {
+			if again := run(seed); !reflect.DeepEqual(first, again) {
+				t.Fatalf("seed %d: interleaving differs between runs:\n%v\n%v", seed, first, again)
+			}
+		}
+	}
+}
+
+func TestScheduler_Step(t *testing.T) {
+	tasksettest.Test(t, 0, func(t *testing.T, s *tasksettest.Scheduler) {
+		ts := taskset.NewTaskSet(s.Middleware())
+		ts.New(func(ctx context.Context, depend taskset.Depend) (interface{}, error) {
+			return nil, nil
+		})
+		ts.Start(context.Background())
+
+		steps := 0
+		for s.Step() {
+			steps++
+		}
+		if steps != 1 {
+			t.Errorf("steps = %d, want 1", steps)
+		}
+
+		done := ts.WaitC()
+		synctest.Wait()
+
+		select {
+		case <-done:
+		default:
+			t.Errorf("task set isn't done after the last step")
+		}
+	})
+}